@@ -0,0 +1,277 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Number of records read from the store at a time while exporting.
+const exportPageSize = 500
+
+// queryFromRequest builds a query from the q parameter, in the syntax of
+// ParseQuery, refined by the channel, user, text, after and before parameters
+func queryFromRequest(r *http.Request) (*Query, error) {
+	q, err := ParseQuery(r.FormValue("q"))
+	if err != nil {
+		return nil, err
+	}
+
+	if ch := r.FormValue("channel"); ch != "" {
+		if _, err := strconv.Atoi(ch); err != nil {
+			return nil, fmt.Errorf("invalid channel %q", ch)
+		}
+		q.Channels = []string{ch}
+	}
+	if user := r.FormValue("user"); user != "" {
+		q.Users = []string{user}
+	}
+	if text := r.FormValue("text"); text != "" {
+		q.Text = append(q.Text, text)
+	}
+	if after := r.FormValue("after"); after != "" {
+		if q.After, err = parseQueryTime(after); err != nil {
+			return nil, err
+		}
+	}
+	if before := r.FormValue("before"); before != "" {
+		if q.Before, err = parseQueryTime(before); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// recordWriter writes records in one of the export formats
+type recordWriter interface {
+	Write(r *Record) error
+	// Flush writes any buffered records to the response
+	Flush() error
+	// Close ends the output
+	Close() error
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(r *Record) error { return w.enc.Encode(r) }
+func (w *ndjsonWriter) Flush() error          { return nil }
+func (w *ndjsonWriter) Close() error          { return nil }
+
+type jsonArrayWriter struct {
+	w     http.ResponseWriter
+	count int
+}
+
+func (w *jsonArrayWriter) Write(r *Record) error {
+	p, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if w.count == 0 {
+		sep = "[\n"
+	}
+	w.count++
+	_, err = w.w.Write(append([]byte(sep), p...))
+	return err
+}
+
+func (w *jsonArrayWriter) Flush() error {
+	return nil
+}
+
+func (w *jsonArrayWriter) Close() error {
+	end := "\n]\n"
+	if w.count == 0 {
+		end = "[]\n"
+	}
+	_, err := w.w.Write([]byte(end))
+	return err
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+var csvHeader = []string{"id", "time", "server", "kind", "channel", "user", "text"}
+
+func (w *csvWriter) Write(r *Record) error {
+	return w.w.Write([]string{
+		r.ID,
+		r.Time.Format(time.RFC3339Nano),
+		r.Server,
+		string(r.Kind),
+		r.Channel,
+		r.User,
+		r.Text,
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) Close() error {
+	return w.Flush()
+}
+
+// exportHandler streams the records matching the request, oldest first, as
+// NDJSON (the default), a JSON array or CSV depending on the format parameter
+func exportHandler(store DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q, err := queryFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q.Reverse = true
+
+		var out recordWriter
+		format := r.FormValue("format")
+		switch format {
+		case "", "ndjson":
+			format = "ndjson"
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			out = &ndjsonWriter{enc: json.NewEncoder(w)}
+		case "json":
+			w.Header().Set("Content-Type", "application/json")
+			out = &jsonArrayWriter{w: w}
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			cw := csv.NewWriter(w)
+			cw.Write(csvHeader)
+			out = &csvWriter{w: cw}
+		default:
+			http.Error(w, fmt.Sprintf("unknown format %q", format), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="chanbot-export.`+format+`"`)
+
+		flusher, _ := w.(http.Flusher)
+		for {
+			page, err := store.Search(q, exportPageSize)
+			if err != nil {
+				// the response has already started, so all we can do is stop
+				errLog.Printf("export failed: %v", err)
+				return
+			}
+			for _, rec := range page {
+				if err := out.Write(rec); err != nil {
+					return
+				}
+			}
+			if len(page) < exportPageSize {
+				break
+			}
+			q.Cursor = cursorOf(page[len(page)-1])
+			if err := out.Flush(); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		out.Close()
+	}
+}
+
+// default and maximum number of messages in an /api/messages page
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// messagesPage is the response of /api/messages
+type messagesPage struct {
+	Messages []*Record `json:"messages"` // oldest first
+	// Older and Newer are the cursors of the adjacent pages, in either direction
+	Older string `json:"older,omitempty"`
+	Newer string `json:"newer,omitempty"`
+	// More reports whether there are more messages in the requested direction
+	More bool `json:"more"`
+}
+
+// messagesHandler serves a page of the records matching the request as JSON.
+// Pages are read backwards from the newest message, or from the cursor
+// parameter, or forwards from the cursor with direction=newer. The newer
+// cursor is set even on the last page, so it can be polled for new messages.
+func messagesHandler(store DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q, err := queryFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if cursor := r.FormValue("cursor"); cursor != "" {
+			if _, _, err := parseCursor(cursor); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			q.Cursor = cursor
+		}
+		switch dir := r.FormValue("direction"); dir {
+		case "", "older":
+		case "newer":
+			q.Reverse = true
+		default:
+			http.Error(w, fmt.Sprintf("invalid direction %q", dir), http.StatusBadRequest)
+			return
+		}
+		limit := defaultPageSize
+		if s := r.FormValue("limit"); s != "" {
+			if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > maxPageSize {
+				http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxPageSize), http.StatusBadRequest)
+				return
+			}
+		}
+
+		records, err := store.Search(q, limit+1)
+		if err != nil {
+			errLog.Printf("failed to search messages: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		page := &messagesPage{Messages: records, More: len(records) > limit}
+		if page.More {
+			page.Messages = records[:limit]
+		}
+		if !q.Reverse {
+			reverse(page.Messages)
+		}
+
+		if n := len(page.Messages); n > 0 {
+			page.Newer = cursorOf(page.Messages[n-1])
+			// paging forwards from a cursor always leaves older messages behind
+			if q.Reverse && q.Cursor != "" || !q.Reverse && page.More {
+				page.Older = cursorOf(page.Messages[0])
+			}
+		} else if q.Reverse {
+			page.Newer = q.Cursor
+		}
+		if page.Messages == nil {
+			page.Messages = []*Record{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+	}
+}
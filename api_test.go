@@ -0,0 +1,127 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// getPage requests /api/messages with params from handler
+func getPage(t *testing.T, handler http.Handler, params url.Values) *messagesPage {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/messages?"+params.Encode(), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", params.Encode(), w.Code, w.Body)
+	}
+	var page messagesPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	return &page
+}
+
+func TestMessagesPaging(t *testing.T) {
+	store := NewMemDB(100)
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	for i := 1; i <= 7; i++ {
+		channel := "1"
+		if i%2 == 0 {
+			channel = "2"
+		}
+		store.Put(&Record{Time: start.Add(time.Duration(i) * time.Minute), Channel: channel, User: "foo", Text: strconv.Itoa(i)})
+	}
+	handler := messagesHandler(store)
+
+	// back from the newest message
+	var newer []string
+	params := url.Values{"limit": {"3"}}
+	for _, want := range []struct {
+		ids  []string
+		more bool
+	}{
+		{[]string{"5", "6", "7"}, true},
+		{[]string{"2", "3", "4"}, true},
+		{[]string{"1"}, false},
+	} {
+		page := getPage(t, handler, params)
+		if !reflect.DeepEqual(ids(page.Messages), want.ids) || page.More != want.more {
+			t.Fatalf("%s: got %v more %v, want %v more %v", params.Encode(), ids(page.Messages), page.More, want.ids, want.more)
+		}
+		if page.Newer == "" {
+			t.Errorf("%s: no newer cursor", params.Encode())
+		}
+		if page.More != (page.Older != "") {
+			t.Errorf("%s: more is %v but the older cursor is %q", params.Encode(), page.More, page.Older)
+		}
+		newer = append(newer, page.Newer)
+		params.Set("cursor", page.Older)
+	}
+
+	// and forwards again from the oldest page
+	params = url.Values{"limit": {"3"}, "direction": {"newer"}, "cursor": {newer[2]}}
+	for _, want := range []struct {
+		ids  []string
+		more bool
+	}{
+		{[]string{"2", "3", "4"}, true},
+		{[]string{"5", "6", "7"}, false},
+		{[]string{}, false},
+	} {
+		page := getPage(t, handler, params)
+		if !reflect.DeepEqual(ids(page.Messages), want.ids) || page.More != want.more {
+			t.Fatalf("%s: got %v more %v, want %v more %v", params.Encode(), ids(page.Messages), page.More, want.ids, want.more)
+		}
+		if page.Newer == "" || len(page.Messages) > 0 && page.Older == "" {
+			t.Errorf("%s: cursors older %q newer %q", params.Encode(), page.Older, page.Newer)
+		}
+		params.Set("cursor", page.Newer)
+	}
+
+	// a newly stored message shows up when polling the last newer cursor
+	store.Put(&Record{Time: start.Add(time.Hour), Channel: "1", User: "foo", Text: "8"})
+	if page := getPage(t, handler, params); !reflect.DeepEqual(ids(page.Messages), []string{"8"}) {
+		t.Errorf("polling got %v, want [8]", ids(page.Messages))
+	}
+
+	// filters apply to every page
+	params = url.Values{"limit": {"2"}, "channel": {"2"}}
+	page := getPage(t, handler, params)
+	if !reflect.DeepEqual(ids(page.Messages), []string{"4", "6"}) || !page.More {
+		t.Errorf("channel 2: got %v more %v", ids(page.Messages), page.More)
+	}
+	params.Set("cursor", page.Older)
+	page = getPage(t, handler, params)
+	if !reflect.DeepEqual(ids(page.Messages), []string{"2"}) || page.More {
+		t.Errorf("channel 2, older: got %v more %v", ids(page.Messages), page.More)
+	}
+}
+
+func TestMessagesBadRequest(t *testing.T) {
+	handler := messagesHandler(NewMemDB(1))
+	for _, query := range []string{
+		"limit=0",
+		"limit=501",
+		"limit=x",
+		"direction=sideways",
+		"cursor=nope",
+		"channel=abc",
+		"q=re:(",
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/messages?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
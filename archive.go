@@ -0,0 +1,246 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// dayLayout formats the days of the log archive
+	dayLayout = "2006-01-02"
+
+	// Maximum number of search results shown from the log archive.
+	maxArchiveResults = 500
+)
+
+// logArchive reads the chat log and its rotated backups, remembering which
+// days each file covers so that a day can be shown without reading them all
+type logArchive struct {
+	name string
+
+	mu    sync.Mutex
+	files map[string]*archiveFile
+}
+
+type archiveFile struct {
+	modTime time.Time
+	size    int64
+	days    []string
+}
+
+func newLogArchive(name string) *logArchive {
+	return &logArchive{name: name, files: make(map[string]*archiveFile)}
+}
+
+// scan returns the log files, oldest first, and the days each of them
+// covers. Only files that changed since the last scan are read, and without
+// holding a.mu, so a slow read does not hold up other requests.
+func (a *logArchive) scan() ([]string, map[string]*archiveFile, error) {
+	paths, err := logFiles(a.name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a.mu.Lock()
+	cached := a.files
+	a.mu.Unlock()
+
+	files := make(map[string]*archiveFile, len(paths))
+	var found []string
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			// rotated away since logFiles listed it
+			continue
+		}
+		f := cached[path]
+		if f == nil || !f.modTime.Equal(fi.ModTime()) || f.size != fi.Size() {
+			f = &archiveFile{modTime: fi.ModTime(), size: fi.Size()}
+			err := readLogFile(path, func(r *Record) error {
+				day := r.Time.Format(dayLayout)
+				if n := len(f.days); n == 0 || f.days[n-1] != day {
+					f.days = append(f.days, day)
+				}
+				return nil
+			})
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		files[path] = f
+		found = append(found, path)
+	}
+
+	// forget the files lumberjack has removed
+	a.mu.Lock()
+	a.files = files
+	a.mu.Unlock()
+	return found, files, nil
+}
+
+// index returns the days in the archive, oldest first, and the files holding
+// messages from each of them
+func (a *logArchive) index() ([]string, map[string][]string, error) {
+	paths, files, err := a.scan()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byDay := make(map[string][]string)
+	var days []string
+	for _, path := range paths {
+		for _, day := range files[path].days {
+			if len(byDay[day]) == 0 {
+				days = append(days, day)
+			}
+			byDay[day] = append(byDay[day], path)
+		}
+	}
+	sort.Strings(days)
+	return days, byDay, nil
+}
+
+// mayMatch reports whether f has messages from the days between the after:
+// and before: of q
+func (f *archiveFile) mayMatch(q *Query) bool {
+	if len(f.days) == 0 {
+		return false
+	}
+	if !q.After.IsZero() && f.days[len(f.days)-1] < q.After.In(time.Local).Format(dayLayout) {
+		return false
+	}
+	if !q.Before.IsZero() && f.days[0] > q.Before.In(time.Local).Format(dayLayout) {
+		return false
+	}
+	return true
+}
+
+// day returns the messages logged on day, oldest first
+func (a *logArchive) day(day string, paths []string) ([]*Record, error) {
+	var records []*Record
+	for _, path := range paths {
+		err := readLogFile(path, func(r *Record) error {
+			if r.Time.Format(dayLayout) == day {
+				records = append(records, r)
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// search returns the newest count messages in the archive matching q, oldest
+// first, and whether older matches were left out. Files are read newest
+// first, skipping those outside the days of q, until count are found.
+func (a *logArchive) search(q *Query, count int) ([]*Record, bool, error) {
+	paths, files, err := a.scan()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var records []*Record
+	truncated := false
+	for i := len(paths) - 1; i >= 0 && !truncated; i-- {
+		if !files[paths[i]].mayMatch(q) {
+			continue
+		}
+		var matches []*Record
+		err := readLogFile(paths[i], func(r *Record) error {
+			if q.Match(r) {
+				matches = append(matches, r)
+			}
+			if len(matches) > count {
+				matches = matches[1:]
+				truncated = true
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, false, err
+		}
+		records = append(matches, records...)
+		if len(records) > count {
+			records = records[len(records)-count:]
+			truncated = true
+		}
+	}
+	return records, truncated, nil
+}
+
+// archiveHandler serves /archive/, the days in the chat log archive, with
+// /archive/{day} showing the messages of a day and /archive/?q= searching
+// all of them in the syntax of ParseQuery
+func archiveHandler(a *logArchive, tmpl *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		days, byDay, err := a.index()
+		if err != nil {
+			errLog.Printf("failed to read the log archive: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		data := struct {
+			Days      []string
+			Day       string
+			Prev      string
+			Next      string
+			Query     string
+			Messages  []*Record
+			Truncated bool
+		}{Days: days, Query: r.FormValue("q")}
+
+		if day := strings.TrimPrefix(r.URL.Path, "/archive/"); day != "" {
+			i := sort.SearchStrings(days, day)
+			if i == len(days) || days[i] != day {
+				http.Error(w, "Not found", http.StatusNotFound)
+				return
+			}
+			data.Day = day
+			if i > 0 {
+				data.Prev = days[i-1]
+			}
+			if i+1 < len(days) {
+				data.Next = days[i+1]
+			}
+			data.Messages, err = a.day(day, byDay[day])
+		} else if data.Query != "" {
+			q, perr := ParseQuery(data.Query)
+			if perr != nil {
+				http.Error(w, perr.Error(), http.StatusBadRequest)
+				return
+			}
+			data.Messages, data.Truncated, err = a.search(q, maxArchiveResults)
+		}
+		if err != nil {
+			errLog.Printf("failed to read the log archive: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(w, "archive.html", data); err != nil {
+			errLog.Printf("failed to render the log archive: %v", err)
+		}
+	}
+}
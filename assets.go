@@ -0,0 +1,76 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// embeddedAssets holds the web pages, style sheets and templates, so the
+// binary runs from any directory
+//
+//go:embed home.html css templates
+var embeddedAssets embed.FS
+
+// How long browsers may use the embedded style sheets without revalidating.
+const assetMaxAge = time.Hour
+
+// assets returns the web assets, read from the -assets directory if set
+func assets() fs.FS {
+	if *assetDir != "" {
+		return os.DirFS(*assetDir)
+	}
+	return embeddedAssets
+}
+
+// serveAsset serves the named file from fsys with an ETag of its contents,
+// so clients can revalidate cheaply. Files from the -assets directory are
+// always revalidated, as they may change at any time.
+func serveAsset(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string, maxAge time.Duration) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		// directories and missing files alike
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	if maxAge > 0 && *assetDir == "" {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	// ServeContent answers If-None-Match against the ETag and sets the
+	// content type from the name
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+// assetHandler serves the files under dir in the web assets at prefix
+func assetHandler(prefix, dir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := path.Join(dir, path.Clean("/"+strings.TrimPrefix(r.URL.Path, prefix)))
+		if !fs.ValidPath(name) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		serveAsset(w, r, assets(), name, assetMaxAge)
+	}
+}
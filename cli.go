@@ -0,0 +1,70 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// commands can be run in place of the bot, e.g. chanbot search user:foo ch:36
+var commands = map[string]func(store DB, args []string) error{
+	"generate": generateCommand,
+	"import":   importCommand,
+	"prune":    pruneCommand,
+	"search":   searchCommand,
+}
+
+func runCommand(store DB, args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return cmd(store, args[1:])
+}
+
+// joinQuery joins command line arguments into a query, quoting arguments
+// that the shell has already unquoted. After a key only the value is quoted,
+// so re:"a b" keeps its key.
+func joinQuery(args []string) string {
+	terms := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t") && !strings.Contains(arg, `"`) {
+			if key, value, ok := strings.Cut(arg, ":"); ok && queryKeys[strings.ToLower(key)] {
+				arg = key + `:"` + value + `"`
+			} else {
+				arg = `"` + arg + `"`
+			}
+		}
+		terms[i] = arg
+	}
+	return strings.Join(terms, " ")
+}
+
+func searchCommand(store DB, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	count := fs.Int("n", 20, "maximum number of messages to print")
+	fs.Parse(args)
+
+	q, err := ParseQuery(joinQuery(fs.Args()))
+	if err != nil {
+		return err
+	}
+	results, err := store.Search(q, *count)
+	if err != nil {
+		return err
+	}
+
+	// print oldest first, like the chat log
+	for i := len(results) - 1; i >= 0; i-- {
+		fmt.Println(results[i])
+	}
+	if len(results) > 0 && len(results) == *count {
+		fmt.Println("more: cursor:" + cursorOf(results[len(results)-1]))
+	}
+	return nil
+}
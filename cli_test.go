@@ -0,0 +1,40 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"testing"
+)
+
+func TestJoinQuery(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"user:foo", "ch:36", "hello"}, "user:foo ch:36 hello"},
+		{[]string{"opening prep"}, `"opening prep"`},
+		{[]string{"re:a b"}, `re:"a b"`},
+		{[]string{"User:x y"}, `User:"x y"`},
+		// not a key, so the whole argument is text
+		{[]string{"note: a b"}, `"note: a b"`},
+		// already quoted by the user
+		{[]string{`re:"a b"`}, `re:"a b"`},
+	}
+	for _, tt := range tests {
+		if got := joinQuery(tt.args); got != tt.want {
+			t.Errorf("joinQuery(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+
+	q, err := ParseQuery(joinQuery([]string{"re:a b+", "user:foo"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Regex == nil || q.Regex.String() != "a b+" || len(q.Text) != 0 {
+		t.Errorf("parsed %+v", q)
+	}
+}
@@ -5,9 +5,129 @@
 
 package main
 
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/freechessclub/icsgo"
+)
+
 // DB represents the interface for chanbot data
 type DB interface {
-	Put(msg interface{}) (string, error)
-	Get(id string) (interface{}, error)
-	Search(queryMap map[string]interface{}, count int) ([]interface{}, error)
+	// Put stores r, assigning and returning its ID
+	Put(r *Record) (string, error)
+	Get(id string) (*Record, error)
+	Search(q *Query, count int) ([]*Record, error)
+	// Channels returns the channels that have records
+	Channels() ([]string, error)
+	// Prune removes the records in channel older than before
+	Prune(channel string, before time.Time, dryRun bool) (int, error)
+	// CheckWritable returns an error if records cannot be stored
+	CheckWritable() error
+	Close() error
+}
+
+// openStore opens the DB backend selected by name
+func openStore(name, path string, capacity int) (DB, error) {
+	switch name {
+	case "sqlite":
+		return NewSQLiteDB(path)
+	case "memory":
+		// number records from the time of starting, so that permalinks to
+		// the messages of an earlier run are not handed out again
+		m := NewMemDB(capacity)
+		m.lastID = time.Now().UnixMicro()
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown store %q", name)
+}
+
+// Kind is the type of message a record was logged from
+type Kind string
+
+// kinds of logged messages
+const (
+	KindChannelTell Kind = "channel-tell"
+	KindPrivateTell Kind = "private-tell"
+	KindKibitz      Kind = "kibitz" // kibitzes and whispers, in channel "Game N"
+)
+
+// kibitzPrefix starts the channel of kibitzes and whispers, followed by the
+// game number
+const kibitzPrefix = "Game "
+
+// kindOf returns the kind of a message told in channel
+func kindOf(channel string) Kind {
+	if strings.HasPrefix(channel, kibitzPrefix) {
+		return KindKibitz
+	}
+	return KindChannelTell
+}
+
+// Record is a single logged message, shared by storage, the API and the UI.
+// There are no user titles (GM, TD, ...), as icsgo strips them from the
+// messages it parses.
+type Record struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"` // when chanbot received the message
+	Server  string    `json:"server"`
+	Kind    Kind      `json:"kind"`
+	Channel string    `json:"channel,omitempty"`
+	User    string    `json:"user"`
+	Text    string    `json:"text"`
 }
+
+// NewChannelTellRecord converts a channel tell received from server, which
+// may also be a kibitz or whisper
+func NewChannelTellRecord(server string, m *icsgo.ChannelTell) *Record {
+	return &Record{
+		Time:    time.Now(),
+		Server:  server,
+		Kind:    kindOf(m.Channel),
+		Channel: m.Channel,
+		User:    m.User,
+		Text:    m.Message,
+	}
+}
+
+// NewPrivateTellRecord converts a private tell received from server
+func NewPrivateTellRecord(server string, m *icsgo.PrivateTell) *Record {
+	return &Record{
+		Time:   time.Now(),
+		Server: server,
+		Kind:   KindPrivateTell,
+		User:   m.User,
+		Text:   m.Message,
+	}
+}
+
+// Seq returns the record's ID as a sequence number. IDs increase with every
+// stored record, so clients can resume from the last one they have seen.
+func (r *Record) Seq() int64 {
+	n, _ := strconv.ParseInt(r.ID, 10, 64)
+	return n
+}
+
+// String formats the record the way it appears in the chat log
+func (r *Record) String() string {
+	return r.Time.Format("2006/01/02 15:04:05") + " (" + r.Channel + ") " + r.User + ": " + r.Text
+}
+
+// sortChannels sorts channel numbers numerically
+func sortChannels(channels []string) {
+	sort.Slice(channels, func(i, j int) bool {
+		a, _ := strconv.Atoi(channels[i])
+		b, _ := strconv.Atoi(channels[j])
+		if a != b {
+			return a < b
+		}
+		return channels[i] < channels[j]
+	})
+}
+
+// ErrNotFound is returned when a record does not exist
+var ErrNotFound = errors.New("record not found")
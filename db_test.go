@@ -0,0 +1,41 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"testing"
+
+	"github.com/freechessclub/icsgo"
+)
+
+func TestNewRecords(t *testing.T) {
+	tests := []struct {
+		r    *Record
+		want Record
+	}{
+		{
+			NewChannelTellRecord("ics", &icsgo.ChannelTell{Channel: "36", User: "foo", Message: "hi"}),
+			Record{Server: "ics", Kind: KindChannelTell, Channel: "36", User: "foo", Text: "hi"},
+		},
+		{
+			NewChannelTellRecord("ics", &icsgo.ChannelTell{Channel: "Game 12", User: "foo", Message: "nice move"}),
+			Record{Server: "ics", Kind: KindKibitz, Channel: "Game 12", User: "foo", Text: "nice move"},
+		},
+		{
+			NewPrivateTellRecord("ics", &icsgo.PrivateTell{User: "bar", Message: "search gg"}),
+			Record{Server: "ics", Kind: KindPrivateTell, User: "bar", Text: "search gg"},
+		},
+	}
+	for _, tt := range tests {
+		if tt.r.Time.IsZero() {
+			t.Errorf("%+v has no time", tt.r)
+		}
+		tt.want.Time = tt.r.Time
+		if *tt.r != tt.want {
+			t.Errorf("got %+v, want %+v", *tt.r, tt.want)
+		}
+	}
+}
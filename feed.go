@@ -0,0 +1,135 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of messages in a feed.
+const feedSize = 50
+
+// entryID returns the Atom ID of a record, a tag URI of its time and ID, so
+// it stays unique even if a store starting afresh hands out the ID again
+func entryID(r *Record) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(siteURL, "https://"), "http://")
+	return "tag:" + host + ",2026:message/" + cursorOf(r)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string     `xml:"id"`
+	Title   string     `xml:"title"`
+	Updated string     `xml:"updated"`
+	Author  atomAuthor `xml:"author"`
+	Link    atomLink   `xml:"link"`
+	Content atomText   `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// feedHandler serves the latest messages as Atom feeds, per channel at
+// /feeds/channel/{n}.atom and per user at /feeds/user/{handle}.atom, with
+// entries that keep their IDs across restarts.
+func feedHandler(store DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		kind, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/feeds/"), "/")
+		name, ok := strings.CutSuffix(name, ".atom")
+		if !ok || name == "" || strings.Contains(name, "/") {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		q := &Query{}
+		var title string
+		switch kind {
+		case "channel":
+			if _, err := strconv.Atoi(name); err != nil {
+				http.Error(w, "Not found", http.StatusNotFound)
+				return
+			}
+			q.Channels = []string{name}
+			title = "Channel " + name
+		case "user":
+			q.Users = []string{name}
+			title = "Messages by " + name
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		records, err := store.Search(q, feedSize)
+		if err != nil {
+			errLog.Printf("failed to read feed %s: %v", r.URL.Path, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		self := siteURL + r.URL.Path
+		feed := &atomFeed{
+			ID:    self,
+			Title: title + " - Free Chess Club chanbot",
+			Links: []atomLink{
+				{Rel: "self", Type: "application/atom+xml", Href: self},
+				{Rel: "alternate", Type: "text/html", Href: siteURL + "/"},
+			},
+		}
+		// an empty feed has nothing to date it by, so it is updated now
+		updated := time.Now()
+		if len(records) > 0 {
+			updated = records[0].Time
+		}
+		feed.Updated = updated.UTC().Format(time.RFC3339)
+		for _, rec := range records {
+			feed.Entries = append(feed.Entries, atomEntry{
+				ID:      entryID(rec),
+				Title:   rec.User + " in channel " + rec.Channel,
+				Updated: rec.Time.UTC().Format(time.RFC3339),
+				Author:  atomAuthor{Name: rec.User},
+				Link:    atomLink{Rel: "alternate", Type: "text/html", Href: permalink(rec)},
+				Content: atomText{Type: "text", Body: rec.String()},
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		w.Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+		w.Write([]byte(xml.Header))
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		if err := enc.Encode(feed); err != nil {
+			errLog.Printf("failed to write feed %s: %v", r.URL.Path, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFeed(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	var entryIDs []string
+	// two runs of a memory store, which both assign ID 1
+	for run := 0; run < 2; run++ {
+		store := NewMemDB(10)
+		store.Put(&Record{Time: start.Add(time.Duration(run) * time.Hour), Channel: "36", User: "foo", Text: "hi"})
+		store.Put(&Record{Time: start.Add(time.Duration(run) * time.Hour), Channel: "39", User: "foo", Text: "hi"})
+
+		w := httptest.NewRecorder()
+		feedHandler(store).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feeds/channel/36.atom", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var feed atomFeed
+		if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+			t.Fatal(err)
+		}
+		if len(feed.Entries) != 1 {
+			t.Fatalf("run %d: %d entries, want 1", run, len(feed.Entries))
+		}
+		e := feed.Entries[0]
+		if want := start.Add(time.Duration(run) * time.Hour).Format(time.RFC3339); e.Updated != want || feed.Updated != want {
+			t.Errorf("run %d: updated %s, feed %s, want %s", run, e.Updated, feed.Updated, want)
+		}
+		entryIDs = append(entryIDs, e.ID)
+	}
+	if entryIDs[0] == entryIDs[1] {
+		t.Errorf("both runs have entry ID %s", entryIDs[0])
+	}
+
+	for _, path := range []string{"/feeds/channel/abc.atom", "/feeds/channel/36", "/feeds/game/1.atom", "/feeds/user/.atom"} {
+		w := httptest.NewRecorder()
+		feedHandler(NewMemDB(1)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want %d", path, w.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// subscription is the JSON form of a filter, as sent and received over /ws
+type subscription struct {
+	Channels []string `json:"channels,omitempty"`
+	Users    []string `json:"users,omitempty"`
+	Text     []string `json:"text,omitempty"`
+}
+
+// filter selects the messages pushed to a client. A message matches when
+// its channel and user are in the respective sets and it contains any of the
+// text terms, where empty sets match everything.
+type filter struct {
+	channels map[string]bool
+	users    map[string]bool // lower case
+	text     map[string]bool // lower case
+}
+
+func newFilter() *filter {
+	return &filter{
+		channels: make(map[string]bool),
+		users:    make(map[string]bool),
+		text:     make(map[string]bool),
+	}
+}
+
+// add extends the filter with the entries of s
+func (f *filter) add(s *subscription) {
+	for _, ch := range s.Channels {
+		f.channels[ch] = true
+	}
+	for _, user := range s.Users {
+		f.users[strings.ToLower(user)] = true
+	}
+	for _, text := range s.Text {
+		if text != "" {
+			f.text[strings.ToLower(text)] = true
+		}
+	}
+}
+
+// remove drops the entries of s from the filter
+func (f *filter) remove(s *subscription) {
+	for _, ch := range s.Channels {
+		delete(f.channels, ch)
+	}
+	for _, user := range s.Users {
+		delete(f.users, strings.ToLower(user))
+	}
+	for _, text := range s.Text {
+		delete(f.text, strings.ToLower(text))
+	}
+}
+
+// match reports whether r passes the filter
+func (f *filter) match(r *Record) bool {
+	if len(f.channels) > 0 && !f.channels[r.Channel] {
+		return false
+	}
+	if len(f.users) > 0 && !f.users[strings.ToLower(r.User)] {
+		return false
+	}
+	if len(f.text) == 0 {
+		return true
+	}
+	text := strings.ToLower(r.Text)
+	for t := range f.text {
+		if strings.Contains(text, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// clone returns a copy of the filter
+func (f *filter) clone() *filter {
+	c := newFilter()
+	c.add(f.subscription())
+	return c
+}
+
+// filter returns the records that pass the filter
+func (f *filter) filter(records []*Record) []*Record {
+	var passed []*Record
+	for _, r := range records {
+		if f.match(r) {
+			passed = append(passed, r)
+		}
+	}
+	return passed
+}
+
+// subscription returns the current state of the filter
+func (f *filter) subscription() *subscription {
+	return &subscription{
+		Channels: sortedKeys(f.channels),
+		Users:    sortedKeys(f.users),
+		Text:     sortedKeys(f.text),
+	}
+}
+
+// filterFromRequest builds a filter from the channel, user and text
+// parameters of a request. Each may be repeated, and channels and users may
+// also be comma separated.
+func filterFromRequest(r *http.Request) *filter {
+	r.ParseForm()
+	s := &subscription{Text: r.Form["text"]}
+	for _, ch := range r.Form["channel"] {
+		s.Channels = append(s.Channels, splitList(ch)...)
+	}
+	for _, user := range r.Form["user"] {
+		s.Users = append(s.Users, splitList(user)...)
+	}
+	f := newFilter()
+	f.add(s)
+	return f
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,241 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// archiveGenerator writes a static archive of the chat, with an HTML and a
+// plain text page per channel per day and a calendar of the days on the
+// index page. Records must be added in order.
+type archiveGenerator struct {
+	dir  string
+	tmpl *template.Template
+
+	day      string
+	channels map[string][]*Record // the messages of day by channel
+
+	days    map[string][]string // the channels of each written day
+	skipped int                 // messages from outside numbered channels
+}
+
+func newArchiveGenerator(dir string, tmpl *template.Template) *archiveGenerator {
+	return &archiveGenerator{
+		dir:      dir,
+		tmpl:     tmpl,
+		channels: make(map[string][]*Record),
+		days:     make(map[string][]string),
+	}
+}
+
+// add adds a record to the archive, writing out the previous day once r
+// starts a new one. Only numbered channels are archived, as the channel names
+// the files: kibitzes ("Game 12") and anything else are skipped.
+func (g *archiveGenerator) add(r *Record) error {
+	if n, err := strconv.Atoi(r.Channel); err != nil || n < 0 || strconv.Itoa(n) != r.Channel {
+		g.skipped++
+		return nil
+	}
+	if day := r.Time.Format(dayLayout); day != g.day {
+		if err := g.flush(); err != nil {
+			return err
+		}
+		g.day = day
+	}
+	g.channels[r.Channel] = append(g.channels[r.Channel], r)
+	return nil
+}
+
+// flush writes the pages of the current day
+func (g *archiveGenerator) flush() error {
+	if len(g.channels) == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(g.dir, g.day)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var channels []string
+	for ch, records := range g.channels {
+		channels = append(channels, ch)
+		data := struct {
+			Day      string
+			Channel  string
+			Messages []*Record
+		}{g.day, ch, records}
+		if err := g.render(filepath.Join(dir, ch+".html"), "static-day.html", data); err != nil {
+			return err
+		}
+		if err := writeTextLog(filepath.Join(dir, ch+".txt"), records); err != nil {
+			return err
+		}
+	}
+	sortChannels(channels)
+	g.days[g.day] = channels
+	g.channels = make(map[string][]*Record)
+	return nil
+}
+
+// calendarMonth is a month of the index calendar, as weeks starting on Monday
+type calendarMonth struct {
+	Title string
+	Weeks [][]calendarDay
+}
+
+// calendarDay is a day of the index calendar, zero when padding a week
+type calendarDay struct {
+	Day      int
+	Date     string
+	Channels []string
+}
+
+// close writes the last day, the index and the style sheet
+func (g *archiveGenerator) close() error {
+	if err := g.flush(); err != nil {
+		return err
+	}
+
+	var days []string
+	for day := range g.days {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+
+	// newest month first
+	var months []calendarMonth
+	for i := len(days) - 1; i >= 0; {
+		t, err := time.Parse(dayLayout, days[i])
+		if err != nil {
+			return err
+		}
+		first := t.AddDate(0, 0, 1-t.Day())
+		m := calendarMonth{Title: first.Format("January 2006")}
+		var week []calendarDay
+		for j := 0; j < (int(first.Weekday())+6)%7; j++ {
+			week = append(week, calendarDay{})
+		}
+		for d := first; d.Month() == first.Month(); d = d.AddDate(0, 0, 1) {
+			date := d.Format(dayLayout)
+			week = append(week, calendarDay{Day: d.Day(), Date: date, Channels: g.days[date]})
+			if len(week) == 7 {
+				m.Weeks = append(m.Weeks, week)
+				week = nil
+			}
+		}
+		if len(week) > 0 {
+			for len(week) < 7 {
+				week = append(week, calendarDay{})
+			}
+			m.Weeks = append(m.Weeks, week)
+		}
+		months = append(months, m)
+
+		for i >= 0 && days[i] >= first.Format(dayLayout) {
+			i--
+		}
+	}
+
+	if err := g.render(filepath.Join(g.dir, "index.html"), "static-index.html", months); err != nil {
+		return err
+	}
+	css, err := fs.ReadFile(assets(), "css/pages.css")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(g.dir, "pages.css"), css, 0644)
+}
+
+func (g *archiveGenerator) render(path, name string, data interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := g.tmpl.ExecuteTemplate(f, name, data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to render %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+// writeTextLog writes records to path in the format of the chat log
+func writeTextLog(path string, records []*Record) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, r := range records {
+		fmt.Fprintln(w, r)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func generateCommand(store DB, args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	out := fs.String("out", "archive", "directory to write the archive to")
+	fromLogs := fs.Bool("from-logs", false, "read the chat log and its backups instead of the store")
+	name := fs.String("log", logFile, "chat log to read with -from-logs")
+	fs.Parse(args)
+
+	tmpl, err := parseTemplates(assets())
+	if err != nil {
+		return err
+	}
+	g := newArchiveGenerator(*out, tmpl)
+
+	if *fromLogs {
+		files, err := logFiles(*name)
+		if err != nil {
+			return err
+		}
+		for _, path := range files {
+			if err := readLogFile(path, g.add); err != nil {
+				return fmt.Errorf("failed to read %s: %v", path, err)
+			}
+		}
+	} else {
+		q := &Query{Reverse: true}
+		for {
+			page, err := store.Search(q, exportPageSize)
+			if err != nil {
+				return err
+			}
+			for _, r := range page {
+				if err := g.add(r); err != nil {
+					return err
+				}
+			}
+			if len(page) < exportPageSize {
+				break
+			}
+			q.Cursor = cursorOf(page[len(page)-1])
+		}
+	}
+
+	if err := g.close(); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d days to %s\n", len(g.days), *out)
+	if g.skipped > 0 {
+		fmt.Printf("skipped %d messages from outside numbered channels\n", g.skipped)
+	}
+	return nil
+}
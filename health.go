@@ -0,0 +1,155 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// health tracks the state of the bot for /healthz and /readyz
+type health struct {
+	start time.Time
+	hub   *hub
+	store DB
+	// staleAfter is how long the server can stay silent before the session
+	// is considered dead
+	staleAfter time.Duration
+
+	lastOutput int64 // unix nanoseconds, zero before any output
+
+	mu       sync.Mutex
+	channels []string        // to join in the current session
+	joined   map[string]bool // the channels the server confirmed joining
+}
+
+// channelJoinedRE matches the server's replies to +ch for a channel that is
+// now on the channel list
+var channelJoinedRE = regexp.MustCompile(`\[(\d+)\] (?:added to|is already on) your channel list`)
+
+func newHealth(h *hub, store DB, staleAfter time.Duration) *health {
+	return &health{start: time.Now(), hub: h, store: store, staleAfter: staleAfter}
+}
+
+// sawOutput records that the server has sent something
+func (s *health) sawOutput() {
+	atomic.StoreInt64(&s.lastOutput, time.Now().UnixNano())
+}
+
+// setChannels records the channels the current session is about to join
+func (s *health) setChannels(channels []string) {
+	s.mu.Lock()
+	s.channels = channels
+	s.joined = make(map[string]bool)
+	s.mu.Unlock()
+}
+
+// sawMessage records the channels that server output confirms joining
+func (s *health) sawMessage(text string) {
+	matches := channelJoinedRE.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return
+	}
+	s.mu.Lock()
+	for _, m := range matches {
+		s.joined[m[1]] = true
+	}
+	s.mu.Unlock()
+}
+
+// healthReport is the JSON body of /healthz and /readyz
+type healthReport struct {
+	OK         bool     `json:"ok"`
+	Problems   []string `json:"problems,omitempty"`
+	Uptime     float64  `json:"uptime_seconds"`
+	ICS        string   `json:"ics"`
+	LastOutput string   `json:"last_output,omitempty"`
+	// SinceOutput is the time since the last server output in seconds, or
+	// since startup if there has been none
+	SinceOutput float64  `json:"since_last_output_seconds"`
+	Channels    []string `json:"channels"` // joined
+	// MissingChannels are the channels still to be joined
+	MissingChannels []string `json:"missing_channels,omitempty"`
+	Store           string   `json:"store,omitempty"`
+}
+
+// report checks the state of the bot. The bot is alive while the server has
+// not been silent for longer than staleAfter, which the session's keepalive
+// prevents while connected. It is ready once it is also logged in, the
+// server has confirmed joining all its channels and it can store messages. A
+// dropped connection only makes it unready, as the bot reconnects by itself.
+func (s *health) report(ready bool) *healthReport {
+	now := time.Now()
+	rep := &healthReport{
+		Uptime: now.Sub(s.start).Seconds(),
+		ICS:    s.hub.currentStatus(),
+	}
+
+	since := now.Sub(s.start)
+	if last := atomic.LoadInt64(&s.lastOutput); last != 0 {
+		t := time.Unix(0, last)
+		rep.LastOutput = t.Format(time.RFC3339)
+		since = now.Sub(t)
+	}
+	rep.SinceOutput = since.Seconds()
+
+	s.mu.Lock()
+	rep.Channels = []string{}
+	for _, ch := range s.channels {
+		if s.joined[ch] {
+			rep.Channels = append(rep.Channels, ch)
+		} else {
+			rep.MissingChannels = append(rep.MissingChannels, ch)
+		}
+	}
+	wanted := len(s.channels)
+	s.mu.Unlock()
+
+	if since > s.staleAfter {
+		rep.Problems = append(rep.Problems, "no output from "+icsServer+" for "+since.Round(time.Second).String())
+	}
+	if ready {
+		if rep.ICS != statusConnected {
+			rep.Problems = append(rep.Problems, "not logged in to "+icsServer)
+		}
+		if wanted == 0 {
+			rep.Problems = append(rep.Problems, "not joining any channels")
+		} else if len(rep.MissingChannels) > 0 {
+			rep.Problems = append(rep.Problems, "not in channels "+strings.Join(rep.MissingChannels, ", "))
+		}
+		if err := s.store.CheckWritable(); err != nil {
+			rep.Store = err.Error()
+			rep.Problems = append(rep.Problems, "store is not writable")
+		} else {
+			rep.Store = "writable"
+		}
+	}
+	rep.OK = len(rep.Problems) == 0
+	return rep
+}
+
+// handler serves the health report, with status 503 when there are problems.
+// With ready set it reports readiness rather than liveness.
+func (s *health) handler(ready bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rep := s.report(ready)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !rep.OK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(rep)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHealthChannels(t *testing.T) {
+	h := newHub(NewMemDB(1))
+	hc := newHealth(h, NewMemDB(1), time.Hour)
+	hc.sawOutput()
+	h.setStatus(statusConnected)
+
+	check := func(joined, missing []string, ready bool) {
+		t.Helper()
+		rep := hc.report(true)
+		if !reflect.DeepEqual(rep.Channels, joined) || !reflect.DeepEqual(rep.MissingChannels, missing) || rep.OK != ready {
+			t.Errorf("channels %v, missing %v, ok %v (%v), want %v, %v, %v", rep.Channels, rep.MissingChannels, rep.OK, rep.Problems, joined, missing, ready)
+		}
+		if !hc.report(false).OK {
+			t.Errorf("not alive: %v", hc.report(false).Problems)
+		}
+	}
+
+	check([]string{}, nil, false)
+	hc.setChannels([]string{"36", "39", "50"})
+	check([]string{}, []string{"36", "39", "50"}, false)
+	hc.sawMessage("[36] added to your channel list.")
+	hc.sawMessage("You are not in channel 1.")
+	check([]string{"36"}, []string{"39", "50"}, false)
+	hc.sawMessage("[39] added to your channel list.\n[50] is already on your channel list.")
+	check([]string{"36", "39", "50"}, nil, true)
+
+	// a new session joins its channels again
+	hc.setChannels([]string{"36"})
+	check([]string{}, []string{"36"}, false)
+}
@@ -0,0 +1,407 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// Number of events queued for a client before it is considered too slow
+	// and disconnected.
+	sendQueueSize = 256
+
+	// Maximum number of messages sent in one history-batch.
+	historySize = 1000
+
+	// Number of recent messages kept in memory for resuming clients.
+	liveBufferSize = 1024
+
+	// Maximum number of messages read from the store for one history-batch.
+	maxHistoryScan = 10000
+)
+
+// client is a connection subscribed to the hub
+type client struct {
+	// send carries the events for the client. It is closed when the client
+	// is unsubscribed, including when it falls too far behind.
+	send chan *event
+
+	// filter selects the messages sent to the client, guarded by the hub lock
+	filter *filter
+
+	// floor is the sequence number of the newest message in the client's
+	// history. Messages up to it are not sent again. Guarded by the hub lock.
+	floor int64
+}
+
+// hub writes incoming messages to the chat log and the store, and fans them
+// out to all subscribed clients as they arrive
+type hub struct {
+	store   DB
+	mu      sync.Mutex
+	clients map[*client]bool
+	status  string
+	recent  []*Record // the last published messages, oldest first
+}
+
+func newHub(store DB) *hub {
+	return &hub{
+		store:   store,
+		clients: make(map[*client]bool),
+		status:  statusConnecting,
+	}
+}
+
+// publish logs and stores r, then sends it to every client. The writes happen
+// before taking the hub lock, so slow disks do not hold up subscribers. A
+// message stored but not yet sent may show up in a new client's history, so
+// clients skip the messages their history already covers. A message that
+// fails to be stored is still sent, without an ID, so it has no permalink and
+// is never in any history.
+func (h *hub) publish(r *Record) {
+	// the log line carries the record's own timestamp, so the importer can
+	// match it against the store
+	start := time.Now()
+	log.Println(r)
+	logWriteSeconds.since("chat", start)
+	start = time.Now()
+	_, err := h.store.Put(r)
+	logWriteSeconds.since("store", start)
+	if err != nil {
+		errLog.Printf("failed to store message: %v", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil {
+		if len(h.recent) == liveBufferSize {
+			h.recent = append(h.recent[:0], h.recent[1:]...)
+		}
+		h.recent = append(h.recent, r)
+	}
+	h.broadcast(messageEvent(r))
+}
+
+// setStatus records the state of the ICS session and tells every client
+func (h *hub) setStatus(status string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status = status
+	h.broadcast(statusEvent(status))
+}
+
+// currentStatus returns the state of the ICS session
+func (h *hub) currentStatus() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+// broadcast queues e for every client, skipping messages that do not pass
+// the client's filter or that its history already holds. The caller must
+// hold h.mu.
+func (h *hub) broadcast(e *event) {
+	for c := range h.clients {
+		if m := e.Message; m != nil && (!c.filter.match(m) || m.ID != "" && m.Seq() <= c.floor) {
+			continue
+		}
+		h.queue(c, e)
+	}
+}
+
+// queue queues e for c, dropping the client if its queue is full. The caller
+// must hold h.mu.
+func (h *hub) queue(c *client, e *event) {
+	select {
+	case c.send <- e:
+	default:
+		sendQueueDrops.inc()
+		delete(h.clients, c)
+		close(c.send)
+	}
+}
+
+// reply queues e for c alone
+func (h *hub) reply(c *client, e *event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[c] {
+		h.queue(c, e)
+	}
+}
+
+// handle applies a command from c
+func (h *hub) handle(c *client, cmd *command) {
+	if cmd.Type == commandHistory {
+		h.mu.Lock()
+		f := c.filter.clone()
+		h.mu.Unlock()
+
+		// paging through the store can be slow, so it happens without the lock
+		e, err := h.page(f, cmd.Before, cmd.Count)
+		if err != nil {
+			e = errorEvent(err.Error())
+		}
+		h.reply(c, e)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.clients[c] {
+		return
+	}
+
+	switch cmd.Type {
+	case commandSubscribe:
+		c.filter.add(&cmd.subscription)
+	case commandUnsubscribe:
+		s := &cmd.subscription
+		if len(s.Channels) == 0 && len(s.Users) == 0 && len(s.Text) == 0 {
+			c.filter = newFilter()
+		} else {
+			c.filter.remove(s)
+		}
+	default:
+		h.queue(c, errorEvent("unknown command "+strconv.Quote(cmd.Type)))
+		return
+	}
+	h.queue(c, subscriptionEvent(c.filter.subscription()))
+}
+
+// subscribe registers a new client receiving the messages that pass f,
+// queueing the session status and history ahead of any new messages. The
+// history holds the messages published after the sequence number since or, if
+// since is empty, the most recent backfill messages.
+func (h *hub) subscribe(f *filter, since string, backfill int) *client {
+	c := &client{
+		send:   make(chan *event, sendQueueSize),
+		filter: f,
+	}
+
+	// the history is read without the lock, so that slow store reads do not
+	// hold up publishing, and the messages sent meanwhile are caught up on
+	// once the lock is taken
+	h.mu.Lock()
+	top := h.lastSeq()
+	h.mu.Unlock()
+
+	var notice *event
+	var history []*Record
+	var truncated, more bool
+	var err error
+	if since != "" {
+		if history, truncated, err = h.missed(since); err != nil {
+			notice = errorEvent("cannot resume from " + strconv.Quote(since) + ", sending recent history")
+			since, err = "", nil
+		}
+		history = f.filter(history)
+	}
+	if since == "" {
+		history, more, truncated, err = searchFiltered(h.store, f, "", clampHistory(backfill))
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c.send <- statusEvent(h.status)
+	if notice != nil {
+		c.send <- notice
+	}
+	if err != nil {
+		errLog.Printf("failed to read history: %v", err)
+		c.send <- errorEvent("history is unavailable")
+		history = nil
+	} else {
+		floor := top
+		if n := len(history); n > 0 && history[n-1].Seq() > floor {
+			floor = history[n-1].Seq()
+		}
+		for _, r := range h.recent {
+			if r.Seq() > floor && f.match(r) {
+				history = append(history, r)
+			}
+		}
+		e := historyEvent(history)
+		e.Truncated = truncated
+		e.More = more
+		c.send <- e
+	}
+	c.floor = h.lastSeq()
+	if n := len(history); n > 0 && history[n-1].Seq() > c.floor {
+		c.floor = history[n-1].Seq()
+	}
+
+	h.clients[c] = true
+	h.broadcast(viewerCountEvent(len(h.clients)))
+	return c
+}
+
+// lastSeq returns the sequence number of the last message sent to clients,
+// or 0 if there is none. The caller must hold h.mu.
+func (h *hub) lastSeq() int64 {
+	if n := len(h.recent); n > 0 {
+		return h.recent[n-1].Seq()
+	}
+	return 0
+}
+
+// missed returns up to historySize of the newest messages published after
+// the one with sequence number since, oldest first, and whether older ones
+// were left out
+func (h *hub) missed(since string) ([]*Record, bool, error) {
+	seq, err := strconv.ParseInt(since, 10, 64)
+	if err != nil {
+		return nil, false, err
+	}
+
+	h.mu.Lock()
+	if n := len(h.recent); n > 0 && seq > h.recent[n-1].Seq() {
+		h.mu.Unlock()
+		// the client saw messages this process never published
+		return nil, false, fmt.Errorf("unknown sequence number %d", seq)
+	}
+	if len(h.recent) > 0 && seq >= h.recent[0].Seq() {
+		i := sort.Search(len(h.recent), func(i int) bool { return h.recent[i].Seq() > seq })
+		// copied, as publish shifts h.recent in place
+		missed := append([]*Record(nil), h.recent[i:]...)
+		h.mu.Unlock()
+		if len(missed) > historySize {
+			return missed[len(missed)-historySize:], true, nil
+		}
+		return missed, false, nil
+	}
+	h.mu.Unlock()
+
+	// older than the live buffer, so look in the store
+	last, err := h.store.Get(since)
+	if err != nil {
+		return nil, false, err
+	}
+	results, err := h.store.Search(&Query{After: last.Time}, historySize+1)
+	if err != nil {
+		return nil, false, err
+	}
+	t, id, _ := parseCursor(cursorOf(last))
+	var missed []*Record
+	for _, r := range results {
+		if !olderThan(r, t, id) && !isCursorOf(r, t, id) {
+			missed = append(missed, r)
+		}
+	}
+	reverse(missed)
+	if len(missed) > historySize {
+		return missed[len(missed)-historySize:], true, nil
+	}
+	return missed, false, nil
+}
+
+// page returns a history-batch of up to count messages passing f that were
+// published before the one with sequence number before
+func (h *hub) page(f *filter, before int64, count int) (*event, error) {
+	last, err := h.store.Get(strconv.FormatInt(before, 10))
+	if err == ErrNotFound {
+		return nil, fmt.Errorf("unknown sequence number %d", before)
+	}
+	if err != nil {
+		errLog.Printf("failed to read history: %v", err)
+		return nil, fmt.Errorf("history is unavailable")
+	}
+
+	records, more, truncated, err := searchFiltered(h.store, f, cursorOf(last), clampHistory(count))
+	if err != nil {
+		errLog.Printf("failed to read history: %v", err)
+		return nil, fmt.Errorf("history is unavailable")
+	}
+	e := historyEvent(records)
+	e.Before = before
+	e.More = more
+	e.Truncated = truncated
+	return e, nil
+}
+
+// searchFiltered returns up to count of the newest messages passing f and
+// older than cursor, oldest first, and whether there are more. The store
+// matches the channels and users of f, leaving only its text to be matched
+// here, and the search gives up, reporting truncated, after reading
+// maxHistoryScan messages.
+func searchFiltered(store DB, f *filter, cursor string, count int) (records []*Record, more, truncated bool, err error) {
+	if count == 0 {
+		return nil, false, false, nil
+	}
+	q := &Query{
+		Cursor:   cursor,
+		Channels: sortedKeys(f.channels),
+		Users:    sortedKeys(f.users),
+	}
+	scanned := 0
+	for len(records) <= count {
+		page, err := store.Search(q, count+1)
+		if err != nil {
+			return nil, false, false, err
+		}
+		scanned += len(page)
+		for _, r := range page {
+			if f.match(r) {
+				records = append(records, r)
+			}
+		}
+		if len(page) <= count {
+			break
+		}
+		if scanned >= maxHistoryScan {
+			truncated = true
+			break
+		}
+		q.Cursor = cursorOf(page[len(page)-1])
+	}
+
+	more = len(records) > count
+	if more {
+		records = records[:count]
+	}
+	// a client can only page on from a message it has been sent
+	if truncated && len(records) > 0 {
+		more = true
+	}
+	reverse(records)
+	return records, more, truncated, nil
+}
+
+// clampHistory limits a requested number of history messages to
+// 0..historySize, where 0 asks for an empty history-batch
+func clampHistory(n int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > historySize {
+		return historySize
+	}
+	return n
+}
+
+// reverse reverses records in place
+func reverse(records []*Record) {
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+}
+
+// unsubscribe removes a client, if it has not been removed already
+func (h *hub) unsubscribe(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[c] {
+		delete(h.clients, c)
+		close(c.send)
+		h.broadcast(viewerCountEvent(len(h.clients)))
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// drain collects the sequence numbers of the messages sent to c, from history
+// and live alike, until c is unsubscribed
+func drain(c *client) <-chan []int64 {
+	done := make(chan []int64, 1)
+	go func() {
+		var seqs []int64
+		for e := range c.send {
+			for _, m := range e.Messages {
+				seqs = append(seqs, m.Seq())
+			}
+			if e.Message != nil {
+				seqs = append(seqs, e.Message.Seq())
+			}
+		}
+		done <- seqs
+	}()
+	return done
+}
+
+// TestSubscribeWhilePublishing checks that a client subscribing while
+// messages are published gets each of them once and in order, whether from
+// its history or live
+func TestSubscribeWhilePublishing(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	// fewer than fit in the send queue, so the client is never dropped
+	const published = sendQueueSize / 2
+	for round := 0; round < 50; round++ {
+		h := newHub(NewMemDB(published))
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < published; i++ {
+				h.publish(&Record{Time: time.Now(), Channel: "1", User: "foo", Text: "hi"})
+				runtime.Gosched()
+			}
+		}()
+		time.Sleep(time.Duration(round) * 5 * time.Microsecond)
+		c := h.subscribe(newFilter(), "", historySize)
+		done := drain(c)
+		wg.Wait()
+		h.unsubscribe(c)
+
+		seqs := <-done
+		for i, seq := range seqs {
+			if seq != int64(i+1) {
+				t.Fatalf("round %d: message %d has seq %d", round, i, seq)
+			}
+		}
+		if len(seqs) != published {
+			t.Fatalf("round %d: got %d messages, want %d", round, len(seqs), published)
+		}
+	}
+}
+
+func TestSubscribeBackfill(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	h := newHub(NewMemDB(100))
+	for i := 0; i < 5; i++ {
+		h.publish(&Record{Time: time.Now(), Channel: "1", User: "foo", Text: "hi"})
+	}
+	for _, tt := range []struct {
+		backfill, want int
+	}{
+		{-1, 0},
+		{0, 0},
+		{3, 3},
+		{10, 5},
+	} {
+		c := h.subscribe(newFilter(), "", tt.backfill)
+		done := drain(c)
+		h.unsubscribe(c)
+		if seqs := <-done; len(seqs) != tt.want {
+			t.Errorf("backfill %d: got history %v, want %d messages", tt.backfill, seqs, tt.want)
+		}
+	}
+}
+
+// failingDB is a DB that cannot store anything
+type failingDB struct {
+	*MemDB
+}
+
+func (failingDB) Put(*Record) (string, error) {
+	return "", errors.New("disk full")
+}
+
+func TestPublishUnstored(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	errLog.SetOutput(io.Discard)
+	defer errLog.SetOutput(os.Stderr)
+
+	h := newHub(failingDB{NewMemDB(1)})
+	c := h.subscribe(newFilter(), "", historySize)
+	var got []*event
+	done := make(chan struct{})
+	go func() {
+		for e := range c.send {
+			if e.Type == eventMessage {
+				got = append(got, e)
+			}
+		}
+		close(done)
+	}()
+	h.publish(&Record{Time: time.Now(), Channel: "1", User: "foo", Text: "one"})
+	h.publish(&Record{Time: time.Now(), Channel: "1", User: "foo", Text: "two"})
+	h.unsubscribe(c)
+	<-done
+
+	if len(got) != 2 {
+		t.Fatalf("got %d messages, want 2", len(got))
+	}
+	for _, e := range got {
+		if e.Message.ID != "" || e.Seq != 0 {
+			t.Errorf("unstored message %q has ID %q, seq %d", e.Message.Text, e.Message.ID, e.Seq)
+		}
+	}
+}
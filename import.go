@@ -0,0 +1,86 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+// importLogs loads the records in the chat log and its rotated backups into
+// store, skipping records that are already stored, and returns the number of
+// records read and imported
+func importLogs(store DB, name string) (read, imported int, err error) {
+	files, err := logFiles(name)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// the log only has second resolution, so identical messages within the
+	// same second are told apart by counting them
+	var second time.Time
+	seen := make(map[string]int)
+	for _, file := range files {
+		err := readLogFile(file, func(r *Record) error {
+			read++
+			if !r.Time.Equal(second) {
+				second = r.Time
+				seen = make(map[string]int)
+			}
+			key := r.Channel + "\x00" + r.User + "\x00" + r.Text
+			seen[key]++
+
+			stored, err := countStored(store, r)
+			if err != nil {
+				return err
+			}
+			if stored >= seen[key] {
+				return nil
+			}
+			if _, err := store.Put(r); err != nil {
+				return err
+			}
+			imported++
+			return nil
+		})
+		if err != nil {
+			return read, imported, fmt.Errorf("failed to import %s: %v", file, err)
+		}
+	}
+	return read, imported, nil
+}
+
+// countStored returns how many copies of r are stored within the second it was logged
+func countStored(store DB, r *Record) (int, error) {
+	results, err := store.Search(&Query{
+		Users:    []string{r.User},
+		Channels: []string{r.Channel},
+		After:    r.Time,
+		Before:   r.Time.Add(time.Second),
+	}, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, s := range results {
+		if s.Text == r.Text {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func importCommand(store DB, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	name := fs.String("log", logFile, "chat log to import, along with its rotated backups")
+	fs.Parse(args)
+
+	read, imported, err := importLogs(store, *name)
+	fmt.Printf("imported %d of %d logged messages\n", imported, read)
+	return err
+}
@@ -0,0 +1,112 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestImportLogs(t *testing.T) {
+	const repeated = "2026/10/01 13:00:00 (36) foo: gg\n2026/10/01 13:00:00 (36) foo: gg\n"
+
+	tests := []struct {
+		name   string
+		stored []string // messages stored before importing, as log lines
+		files  map[string]string
+		read   int
+		want   int
+	}{
+		{
+			name:  "empty store",
+			files: map[string]string{"chat.log": testLog},
+			read:  4,
+			want:  4,
+		},
+		{
+			name:   "already imported",
+			stored: []string{testLog},
+			files:  map[string]string{"chat.log": testLog},
+			read:   4,
+			want:   0,
+		},
+		{
+			name:  "repeated message",
+			files: map[string]string{"chat.log": repeated},
+			read:  2,
+			want:  2,
+		},
+		{
+			name:   "repeated message, once stored",
+			stored: []string{"2026/10/01 13:00:00 (36) foo: gg\n"},
+			files:  map[string]string{"chat.log": repeated},
+			read:   2,
+			want:   1,
+		},
+		{
+			name:  "backups and the current log",
+			files: map[string]string{"chat-2026-10-01T12-30-00.000.log.gz": testLog, "chat.log": repeated},
+			read:  6,
+			want:  6,
+		},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		store := NewMemDB(100)
+		for i, data := range tt.stored {
+			path := filepath.Join(dir, "stored"+strconv.Itoa(i))
+			writeTestLog(t, path, data)
+			if err := readLogFile(path, func(r *Record) error {
+				_, err := store.Put(r)
+				return err
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for name, data := range tt.files {
+			writeTestLog(t, filepath.Join(dir, name), data)
+		}
+
+		read, imported, err := importLogs(store, filepath.Join(dir, "chat.log"))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if read != tt.read || imported != tt.want {
+			t.Errorf("%s: imported %d of %d, want %d of %d", tt.name, imported, read, tt.want, tt.read)
+		}
+
+		// importing again finds everything stored
+		read, imported, err = importLogs(store, filepath.Join(dir, "chat.log"))
+		if err != nil || imported != 0 {
+			t.Errorf("%s: imported %d of %d again (%v)", tt.name, imported, read, err)
+		}
+	}
+}
+
+func TestCountStored(t *testing.T) {
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	store := NewMemDB(10)
+	for _, r := range []*Record{
+		{Time: at.Add(100 * time.Millisecond), Channel: "36", User: "foo", Text: "gg"},
+		{Time: at.Add(900 * time.Millisecond), Channel: "36", User: "FOO", Text: "gg"},
+		{Time: at.Add(time.Second), Channel: "36", User: "foo", Text: "gg"},
+		{Time: at, Channel: "39", User: "foo", Text: "gg"},
+		{Time: at, Channel: "36", User: "foo", Text: "gg wp"},
+	} {
+		store.Put(r)
+	}
+
+	n, err := countStored(store, &Record{Time: at, Channel: "36", User: "foo", Text: "gg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("countStored = %d, want 2", n)
+	}
+}
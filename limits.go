@@ -0,0 +1,151 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// connLimiter caps the number of live feed connections, both in total and
+// per client IP, and shares a message rate limit between the connections of
+// each IP
+type connLimiter struct {
+	max, maxPerIP int
+	rate          float64 // messages per second per IP
+	burst         float64 // at least one, so rates under 0.5/s still allow messages
+
+	mu    sync.Mutex
+	total int
+	ips   map[string]*ipState
+}
+
+type ipState struct {
+	conns  int
+	tokens float64
+	last   time.Time
+}
+
+func newConnLimiter(max, maxPerIP int, rate float64) *connLimiter {
+	return &connLimiter{
+		max:      max,
+		maxPerIP: maxPerIP,
+		rate:     rate,
+		burst:    math.Max(1, 2*rate),
+		ips:      make(map[string]*ipState),
+	}
+}
+
+// acquire reserves a connection for ip, or returns false if a limit has been
+// reached. A limit of zero or less is no limit.
+func (l *connLimiter) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.ips[ip]
+	if l.max > 0 && l.total >= l.max {
+		return false
+	}
+	if s != nil && l.maxPerIP > 0 && s.conns >= l.maxPerIP {
+		return false
+	}
+	if s == nil {
+		s = &ipState{tokens: l.burst, last: time.Now()}
+		l.ips[ip] = s
+	}
+	s.conns++
+	l.total++
+	return true
+}
+
+// release frees a connection reserved with acquire
+func (l *connLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.ips[ip]
+	if s == nil {
+		return
+	}
+	l.total--
+	if s.conns--; s.conns == 0 {
+		delete(l.ips, ip)
+	}
+}
+
+// allowMessage reports whether a connection from ip may send another message,
+// refilling its tokens at the configured rate
+func (l *connLimiter) allowMessage(ip string) bool {
+	if l.rate <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.ips[ip]
+	if s == nil {
+		return false
+	}
+	now := time.Now()
+	s.tokens += now.Sub(s.last).Seconds() * l.rate
+	if s.tokens > l.burst {
+		s.tokens = l.burst
+	}
+	s.last = now
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+// clientIP returns the IP address of the client making r. Behind a trusted
+// reverse proxy, that is the last address the proxy added to
+// X-Forwarded-For.
+func clientIP(r *http.Request) string {
+	if *trustProxy {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			addrs := strings.Split(fwd[len(fwd)-1], ",")
+			if ip := strings.TrimSpace(addrs[len(addrs)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// checkOrigin accepts WebSocket requests from the origins set with -origins,
+// or only from the same host if none are. An origin of * accepts all.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// not a browser, so there is no page to protect
+		return true
+	}
+	allowed := splitList(*allowedOrigins)
+	if len(allowed) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, o := range allowed {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// tooManyConnections rejects a request over the connection limits
+func tooManyConnections(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "30")
+	http.Error(w, "Too many connections", http.StatusTooManyRequests)
+}
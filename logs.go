@@ -0,0 +1,109 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// logTimeLayout is the timestamp written by log.Ldate|log.Ltime
+const logTimeLayout = "2006/01/02 15:04:05"
+
+// logLineRE matches a chat log line as written by logChannelTell
+var logLineRE = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) \(([^)]*)\) ([^:]+): ?(.*)$`)
+
+// logFiles returns the rotated backups of the chat log followed by the
+// current log, oldest first
+func logFiles(name string) ([]string, error) {
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext)
+
+	// lumberjack names backups <prefix>-<UTC timestamp><ext>[.gz], so they sort by name
+	var files []string
+	for _, pattern := range []string{prefix + "-*" + ext, prefix + "-*" + ext + ".gz"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	if _, err := os.Stat(name); err == nil {
+		files = append(files, name)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return files, nil
+}
+
+// readLogFile parses the records in a chat log file, decompressing rotated
+// backups, and calls fn for each of them in order. Lines that do not start
+// with a timestamp continue the message on the previous line.
+func readLogFile(path string, fn func(*Record) error) error {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var pending *Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		m := logLineRE.FindStringSubmatch(line)
+		if m == nil {
+			if pending != nil {
+				pending.Text += "\n" + line
+			}
+			continue
+		}
+		t, err := time.ParseInLocation(logTimeLayout, m[1], time.Local)
+		if err != nil {
+			continue
+		}
+
+		if pending != nil {
+			if err := fn(pending); err != nil {
+				return err
+			}
+		}
+		pending = &Record{
+			Time:    t,
+			Server:  icsServer,
+			Kind:    kindOf(m[2]),
+			Channel: m[2],
+			User:    m[3],
+			Text:    m[4],
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if pending != nil {
+		return fn(pending)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testLog = `2026/10/01 12:00:00 (36) foo: hello
+2026/10/01 12:00:01 (39) bar: a message
+over two lines
+2026/10/01 12:00:01 (Game 12) baz: nice move
+2026/10/01 12:00:02 (36) foo:
+`
+
+// writeTestLog writes data to path, compressed if path ends in .gz
+func writeTestLog(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if filepath.Ext(path) == ".gz" {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		_, err = gz.Write([]byte(data))
+	} else {
+		_, err = f.Write([]byte(data))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadLogFile(t *testing.T) {
+	type line struct {
+		Time, Channel, User, Text string
+		Kind                      Kind
+	}
+	want := []line{
+		{"2026/10/01 12:00:00", "36", "foo", "hello", KindChannelTell},
+		{"2026/10/01 12:00:01", "39", "bar", "a message\nover two lines", KindChannelTell},
+		{"2026/10/01 12:00:01", "Game 12", "baz", "nice move", KindKibitz},
+		{"2026/10/01 12:00:02", "36", "foo", "", KindChannelTell},
+	}
+
+	for _, name := range []string{"chat.log", "chat-2026-10-02T00-00-00.000.log.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		writeTestLog(t, path, "a line before the first message\n"+testLog)
+
+		var got []line
+		err := readLogFile(path, func(r *Record) error {
+			got = append(got, line{r.Time.Format(logTimeLayout), r.Channel, r.User, r.Text, r.Kind})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: read %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "chat.log")
+	for _, f := range []string{"chat-2026-10-02T00-00-00.000.log", "chat-2026-10-01T00-00-00.000.log.gz", "other.log"} {
+		writeTestLog(t, filepath.Join(dir, f), "")
+	}
+
+	files, err := logFiles(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, "chat-2026-10-01T00-00-00.000.log.gz"),
+		filepath.Join(dir, "chat-2026-10-02T00-00-00.000.log"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("logFiles = %q, want %q", files, want)
+	}
+
+	writeTestLog(t, name, "")
+	files, err = logFiles(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want = append(want, name); !reflect.DeepEqual(files, want) {
+		t.Errorf("logFiles = %q, want %q", files, want)
+	}
+}
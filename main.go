@@ -6,6 +6,8 @@
 package main
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -13,7 +15,10 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -30,8 +35,29 @@ var (
 		"ROBOadmin",
 		"adminBOT",
 	}
-	addr    = flag.String("addr", ":8080", "http service address")
-	logFile = "chat.log"
+	addr           = flag.String("addr", ":8080", "http service address")
+	assetDir       = flag.String("assets", "", "serve home.html, css and templates from this directory instead of the embedded copies, for development")
+	tlsAddr        = flag.String("tlsaddr", ":8443", "https service address, used with -tlscert and -tlskey")
+	tlsCert        = flag.String("tlscert", "", "TLS certificate file, reloaded when it changes")
+	tlsKey         = flag.String("tlskey", "", "TLS private key file, reloaded when it changes")
+	storeType      = flag.String("store", "sqlite", "message store to use (sqlite or memory)")
+	dbFile         = flag.String("db", "chanbot.db", "path to the SQLite message database")
+	memSize        = flag.Int("memsize", 10000, "number of messages kept by the memory store")
+	backfillSize   = flag.Int("backfill", 100, "number of recent messages sent to new live feed clients, 0 for none")
+	retention      = flag.String("retention", "", "per-channel message retention, e.g. 36=365d,39=7d,*=30d (default keep forever)")
+	allowedOrigins = flag.String("origins", "", "comma separated origins allowed to open WebSockets, or * for any (default same host)")
+	maxConns       = flag.Int("maxconns", 1000, "maximum number of live feed connections, 0 for no limit")
+	maxIPConns     = flag.Int("maxipconns", 10, "maximum number of live feed connections per client IP, 0 for no limit")
+	msgRate        = flag.Float64("msgrate", 5, "WebSocket messages per second allowed from each client IP, 0 for no limit")
+	trustProxy     = flag.Bool("trustproxy", false, "take client IPs from X-Forwarded-For, when behind a reverse proxy")
+	staleAfter     = flag.Duration("stale", 30*time.Minute, "time without output from the chess server after which the bot is reported dead")
+	logFile        = "chat.log"
+	siteURL        = "https://chanbot.freechess.club"
+	icsServer      = "freechess.org"
+	icsPort        = "5000"
+
+	// errLog reports errors without mixing them into the chat log
+	errLog = log.New(os.Stderr, "", log.LstdFlags)
 )
 
 const (
@@ -47,8 +73,20 @@ const (
 	// Send pings to peer with this period. Must be less than pongWait.
 	pingPeriod = (pongWait * 9) / 10
 
-	// Poll for messages with this period.
-	msgPeriod = 8 * time.Second
+	// Prune expired messages from the store with this period.
+	pruneInterval = time.Hour
+
+	// Maximum number of search results told back to a user.
+	maxTellResults = 5
+
+	// Delay before reconnecting to the chess server, doubling up to the
+	// maximum while connections keep failing.
+	minReconnectDelay = 5 * time.Second
+	maxReconnectDelay = 5 * time.Minute
+
+	// Send a command to the chess server with this period, so a quiet but
+	// working session still produces output. Must be less than -stale.
+	keepaliveInterval = 5 * time.Minute
 )
 
 var (
@@ -59,83 +97,53 @@ var (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  maxMessageSize,
 	WriteBufferSize: maxMessageSize,
+	CheckOrigin:     checkOrigin,
 }
 
-func readFileIfModified(lastMod time.Time, filename string) ([]byte, time.Time, error) {
-	fi, err := os.Stat(filename)
-	if err != nil {
-		return nil, lastMod, err
-	}
-	if !fi.ModTime().After(lastMod) {
-		return nil, lastMod, nil
-	}
-
-	p, err := os.ReadFile(filepath.Clean(filename))
-	if err != nil {
-		return nil, fi.ModTime(), err
-	}
-	return p, fi.ModTime(), nil
-}
-
-func reader(ws *websocket.Conn) {
+// reader handles the commands of a client until it disconnects, closing the
+// connection with 1013 (try again later) once allow reports it is sending
+// too fast
+func reader(ws *websocket.Conn, h *hub, c *client, allow func() bool) {
 	defer ws.Close()
-	ws.SetReadLimit(512)
+	ws.SetReadLimit(maxMessageSize)
 	ws.SetReadDeadline(time.Now().Add(pongWait))
 	ws.SetPongHandler(func(string) error { ws.SetReadDeadline(time.Now().Add(pongWait)); return nil })
 	for {
-		_, _, err := ws.ReadMessage()
+		_, p, err := ws.ReadMessage()
 		if err != nil {
 			break
 		}
+		if !allow() {
+			msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "message rate exceeded")
+			ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+			break
+		}
+		var cmd command
+		if err := json.Unmarshal(p, &cmd); err != nil {
+			h.reply(c, errorEvent("invalid command: "+err.Error()))
+			continue
+		}
+		h.handle(c, &cmd)
 	}
 }
 
-func writer(ws *websocket.Conn, lastMod time.Time, seek int) {
-	lastError := ""
+func writer(ws *websocket.Conn, c *client) {
 	pingticker := time.NewTicker(pingPeriod)
-	msgticker := time.NewTicker(msgPeriod)
 	defer func() {
 		pingticker.Stop()
-		msgticker.Stop()
 		ws.Close()
 	}()
 
-	sendUpdates := func() error {
-		var p []byte
-		var err error
-
-		p, lastMod, err = readFileIfModified(lastMod, logFile)
-		if err != nil {
-			if s := err.Error(); s != lastError {
-				lastError = s
-				p = []byte(lastError)
-			}
-		} else {
-			lastError = ""
-		}
-
-		if p != nil {
-			size := len(p)
-			if size > seek {
-				ws.SetWriteDeadline(time.Now().Add(writeWait))
-				if err := ws.WriteMessage(websocket.TextMessage, p[seek:]); err != nil {
-					return err
-				}
-				seek = size
-			}
-		}
-
-		return nil
-	}
-
-	if err := sendUpdates(); err != nil {
-		return
-	}
-
 	for {
 		select {
-		case <-msgticker.C:
-			if err := sendUpdates(); err != nil {
+		case e, ok := <-c.send:
+			ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// the hub dropped us for falling behind
+				ws.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := ws.WriteJSON(e); err != nil {
 				return
 			}
 		case <-pingticker.C:
@@ -147,16 +155,40 @@ func writer(ws *websocket.Conn, lastMod time.Time, seek int) {
 	}
 }
 
-func serveWs(w http.ResponseWriter, r *http.Request) {
-	ws, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		panic(fmt.Sprintln("upgrade:", err))
+func wsHandler(h *hub, limits *connLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		if !limits.acquire(ip) {
+			tooManyConnections(w)
+			return
+		}
+		defer limits.release(ip)
+
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader has already replied, e.g. 403 for a disallowed origin
+			errLog.Printf("failed to upgrade connection from %s: %v", clientIP(r), err)
+			return
+		}
+
+		// clients resume from the sequence number of the last message they saw
+		c := h.subscribe(filterFromRequest(r), r.FormValue("since"), backfillFromRequest(r))
+		defer h.unsubscribe(c)
+		clientsConnected.add("websocket", 1)
+		defer clientsConnected.add("websocket", -1)
+
+		go writer(ws, c)
+		reader(ws, h, c, func() bool { return limits.allowMessage(ip) })
 	}
+}
 
-	lastMod := time.Unix(0, 0)
-	seek := 0
-	go writer(ws, lastMod, seek)
-	reader(ws)
+// backfillFromRequest returns the number of history messages asked for with
+// the backfill parameter, or the -backfill default
+func backfillFromRequest(r *http.Request) int {
+	if n, err := strconv.Atoi(r.FormValue("backfill")); err == nil {
+		return n
+	}
+	return *backfillSize
 }
 
 func serveHome(w http.ResponseWriter, r *http.Request) {
@@ -168,45 +200,253 @@ func serveHome(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	http.ServeFile(w, r, "home.html")
+	serveAsset(w, r, assets(), "home.html", 0)
 }
 
-func main() {
-	flag.Parse()
-	http.HandleFunc("/", serveHome)
-	http.Handle("/css/", http.StripPrefix("/css/", http.FileServer(http.Dir("./css"))))
-	http.HandleFunc("/ws", serveWs)
-	server := &http.Server{
-		Addr:              *addr,
-		ReadHeaderTimeout: 3 * time.Second,
+// logChannelTell writes a channel tell to the chat log, the store and the
+// connected clients
+func logChannelTell(h *hub, m *icsgo.ChannelTell) {
+	r := NewChannelTellRecord(icsServer, m)
+	channelTells.inc(r.Channel)
+	h.publish(r)
+}
+
+// handlePrivateTell answers a private tell, running "search <query>" requests
+// against the store
+func handlePrivateTell(client *icsgo.Client, store DB, m *icsgo.PrivateTell) {
+	privateTellsReceived.inc()
+	for _, user := range ignoreList {
+		if m.User == user {
+			return
+		}
+	}
+	// a tell is answered once the first reply to it is sent
+	answered := false
+	reply := func(text string) bool {
+		if err := client.Send([]byte("t " + m.User + " " + text)); err != nil {
+			errLog.Printf("failed to reply to %s: %v", m.User, err)
+			return false
+		}
+		if !answered {
+			answered = true
+			privateTellsAnswered.inc()
+		}
+		return true
 	}
-	go server.ListenAndServe()
 
-	// create a new FICS client
+	if cmd, query, _ := strings.Cut(strings.TrimSpace(m.Message), " "); strings.EqualFold(cmd, "search") {
+		q, err := ParseQuery(query)
+		if err != nil {
+			reply("Invalid search: " + err.Error())
+			return
+		}
+		results, err := store.Search(q, maxTellResults)
+		if err != nil {
+			errLog.Printf("failed to search for %s: %v", m.User, err)
+			reply("Search failed, please try again later.")
+			return
+		}
+		if len(results) == 0 {
+			reply("No messages found.")
+			return
+		}
+		for i := len(results) - 1; i >= 0; i-- {
+			if !reply(results[i].String() + " " + permalink(results[i])) {
+				return
+			}
+		}
+		return
+	}
+
+	reply("Hello " + m.User + ", I am chanbot. See my logs at " + siteURL + "/")
+}
+
+// serve runs a web server, over TLS if it has a TLS config. The bot keeps
+// logging if it fails.
+func serve(server *http.Server) {
+	var err error
+	if server.TLSConfig != nil {
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		errLog.Printf("web server on %s failed: %v", server.Addr, err)
+	}
+}
+
+// session is the connection to the chess server, replaced on reconnects
+var session atomic.Pointer[icsgo.Client]
+
+// runSession logs in to the chess server, joins the channels and relays their
+// messages until the connection fails
+func runSession(h *hub, hc *health, store DB) error {
+	// icsgo's own keepalive never stops, so keepalive below replaces it
 	client, err := icsgo.NewClient(&icsgo.Config{
-		DisableTimeseal: true,
-	}, "freechess.org:5000", "chanbot", "")
+		DisableKeepAlive: true,
+		DisableTimeseal:  true,
+	}, icsServer+":"+icsPort, "chanbot", "")
 	if err != nil {
-		panic(fmt.Sprintf("failed to create a new ICS client: %v", err))
+		return fmt.Errorf("failed to create a new ICS client: %v", err)
 	}
+	session.Store(client)
+	defer client.Destroy()
+	hc.sawOutput()
 
 	// add some delay to make sure that the server is ready to start accepting commands
 	time.Sleep(3 * time.Second)
 
 	// initialization commands here
 	if err := client.Send([]byte("set seek 0")); err != nil {
-		panic(fmt.Sprintf("failed to turn seek off: %v", err))
+		return fmt.Errorf("failed to turn seek off: %v", err)
 	}
 
-	if err := client.Send([]byte("set 1 I am chanbot. See my logs at https://chanbot.freechess.club/")); err != nil {
-		panic(fmt.Sprintf("failed to set note 1: %v", err))
+	if err := client.Send([]byte("set 1 I am chanbot. See my logs at " + siteURL + "/")); err != nil {
+		return fmt.Errorf("failed to set note 1: %v", err)
 	}
 
+	var joining []string
+	for _, ch := range channels {
+		joining = append(joining, strconv.Itoa(ch))
+	}
+	// the server's confirmations are read by the loop below
+	hc.setChannels(joining)
 	for _, ch := range channels {
 		if err := client.Send([]byte(fmt.Sprintf("+ch %d", ch))); err != nil {
-			panic(fmt.Sprintf("failed to add channel %d: %v", ch, err))
+			return fmt.Errorf("failed to add channel %d: %v", ch, err)
+		}
+	}
+	h.setStatus(statusConnected)
+	defer func() {
+		hc.setChannels(nil)
+		h.setStatus(statusDisconnected)
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go keepalive(client, done)
+
+	for {
+		msgs, err := client.Recv()
+		if err == io.EOF {
+			return fmt.Errorf("connection closed by the server")
+		}
+		if err != nil {
+			icsReceiveErrors.inc()
+			return fmt.Errorf("error receiving server output: %v", err)
+		}
+		hc.sawOutput()
+
+		for _, msg := range msgs {
+			handleMessage(client, h, hc, store, msg)
+		}
+	}
+}
+
+// keepalive sends a harmless command to the server until done is closed. Its
+// reply is read by runSession like any other output.
+func keepalive(client *icsgo.Client, done <-chan struct{}) {
+	ticker := time.NewTicker(keepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := client.Send([]byte("date")); err != nil {
+				errLog.Printf("failed to send keepalive: %v", err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// handleMessage handles a message from the server, logging rather than
+// crashing on a panic
+func handleMessage(client *icsgo.Client, h *hub, hc *health, store DB, msg interface{}) {
+	defer func() {
+		if err := recover(); err != nil {
+			errLog.Printf("panic handling %T: %v\n%s", msg, err, debug.Stack())
+		}
+	}()
+
+	switch m := msg.(type) {
+	case *icsgo.ChannelTell:
+		logChannelTell(h, m)
+	case *icsgo.PrivateTell:
+		handlePrivateTell(client, store, m)
+	case *icsgo.Message:
+		hc.sawMessage(m.Message)
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	store, err := openStore(*storeType, *dbFile, *memSize)
+	if err != nil {
+		errLog.Fatalf("failed to open %s store: %v", *storeType, err)
+	}
+
+	if flag.NArg() > 0 {
+		err := runCommand(store, flag.Args())
+		store.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	policy, err := ParseRetention(*retention)
+	if err != nil {
+		errLog.Fatalf("failed to parse retention policy: %v", err)
+	}
+	if len(policy) > 0 {
+		go runPruner(store, policy, pruneInterval)
+	}
+
+	tmpl, err := parseTemplates(assets())
+	if err != nil {
+		errLog.Fatalf("failed to parse templates: %v", err)
+	}
+
+	http.HandleFunc("/", serveHome)
+	http.HandleFunc("/css/", assetHandler("/css/", "css"))
+	h := newHub(store)
+	limits := newConnLimiter(*maxConns, *maxIPConns, *msgRate)
+	http.HandleFunc("/ws", wsHandler(h, limits))
+	http.HandleFunc("/events", sseHandler(h, limits))
+	http.HandleFunc("/api/messages", messagesHandler(store))
+	http.HandleFunc("/api/export", exportHandler(store))
+	http.HandleFunc("/m/", permalinkHandler(store, tmpl))
+	http.HandleFunc("/archive/", archiveHandler(newLogArchive(logFile), tmpl))
+	http.HandleFunc("/feeds/", feedHandler(store))
+	http.HandleFunc("/metrics", metricsHandler)
+	hc := newHealth(h, store, *staleAfter)
+	http.HandleFunc("/healthz", hc.handler(false))
+	http.HandleFunc("/readyz", hc.handler(true))
+	handler := recoverHandler(http.DefaultServeMux)
+	if *tlsCert != "" || *tlsKey != "" {
+		certs, err := newCertReloader(*tlsCert, *tlsKey)
+		if err != nil {
+			errLog.Fatalf("failed to load TLS certificate: %v", err)
 		}
+		go serve(&http.Server{
+			Addr:              *tlsAddr,
+			Handler:           hstsHandler(handler),
+			ReadHeaderTimeout: 3 * time.Second,
+			TLSConfig: &tls.Config{
+				GetCertificate: certs.GetCertificate,
+				MinVersion:     tls.VersionTLS12,
+			},
+		})
+		handler = redirectToHTTPS(handler)
 	}
+	go serve(&http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 3 * time.Second,
+	})
 
 	logger := &lumberjack.Logger{
 		Filename:   logFile,
@@ -215,51 +455,36 @@ func main() {
 		MaxAge:     30,   //days
 		Compress:   true, // disabled by default
 	}
-	log.SetFlags(log.Ldate | log.Ltime)
+	log.SetFlags(0)
 	log.SetOutput(logger)
 
 	// handle interrupts
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
-		client.Destroy()
+		if client := session.Load(); client != nil {
+			client.Destroy()
+		}
+		store.Close()
 		os.Exit(1)
 	}()
 
+	// reconnect until the process is stopped, backing off while the server
+	// keeps failing
+	delay := minReconnectDelay
 	for {
-		msgs, err := client.Recv()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			panic(fmt.Sprintf("error receiving server output: %v", err))
-		}
-		if msgs == nil {
-			continue
+		start := time.Now()
+		err := runSession(h, hc, store)
+		errLog.Printf("disconnected from %s: %v", icsServer, err)
+		if time.Since(start) > maxReconnectDelay {
+			delay = minReconnectDelay
 		}
-
-		for _, msg := range msgs {
-			switch msg.(type) {
-			case *icsgo.ChannelTell:
-				m := msg.(*icsgo.ChannelTell)
-				log.Println("(" + m.Channel + ") " + m.User + ": " + m.Message)
-			case *icsgo.PrivateTell:
-				m := msg.(*icsgo.PrivateTell)
-				ignoreTell := false
-				for _, user := range ignoreList {
-					if m.User == user {
-						ignoreTell = true
-						break
-					}
-				}
-				if ignoreTell {
-					continue
-				}
-				response := "Hello " + m.User + ", I am chanbot. See my logs at https://chanbot.freechess.club/"
-				client.Send([]byte("t " + m.User + " " + response))
-			}
+		time.Sleep(delay)
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
 		}
+		icsReconnects.inc()
+		h.setStatus(statusConnecting)
 	}
-	client.Destroy()
 }
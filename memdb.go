@@ -0,0 +1,144 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemDB is a DB that keeps the most recent records in memory, evicting the
+// oldest record once its capacity is reached
+type MemDB struct {
+	mu      sync.RWMutex
+	records []*Record // ring buffer, with nil slots for pruned records
+	start   int       // index of the oldest record
+	size    int
+	lastID  int64
+}
+
+// NewMemDB creates an in-memory DB that holds up to capacity records
+func NewMemDB(capacity int) *MemDB {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &MemDB{records: make([]*Record, capacity)}
+}
+
+// CheckWritable always succeeds for the in-memory DB
+func (m *MemDB) CheckWritable() error {
+	return nil
+}
+
+// Close is a no-op for the in-memory DB
+func (m *MemDB) Close() error {
+	return nil
+}
+
+// Put stores a copy of r, assigning and returning its ID
+func (m *MemDB) Put(r *Record) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastID++
+	r.ID = strconv.FormatInt(m.lastID, 10)
+	c := *r
+	if m.size < len(m.records) {
+		m.records[(m.start+m.size)%len(m.records)] = &c
+		m.size++
+	} else {
+		m.records[m.start] = &c
+		m.start = (m.start + 1) % len(m.records)
+	}
+	return r.ID, nil
+}
+
+// Get returns the record with the given ID, if it has not been evicted yet
+func (m *MemDB) Get(id string) (*Record, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// IDs are assigned sequentially, so the offset from the oldest ID is the
+	// offset into the ring
+	first := m.lastID - int64(m.size) + 1
+	if n < first || n > m.lastID {
+		return nil, ErrNotFound
+	}
+	r := m.records[(m.start+int(n-first))%len(m.records)]
+	if r == nil {
+		return nil, ErrNotFound
+	}
+	c := *r
+	return &c, nil
+}
+
+// Search returns up to count records matching q, newest first unless
+// q.Reverse is set. A count <= 0 means no limit.
+func (m *MemDB) Search(q *Query, count int) ([]*Record, error) {
+	m.mu.RLock()
+	var matches []*Record
+	for i := m.size - 1; i >= 0; i-- {
+		r := m.records[(m.start+i)%len(m.records)]
+		if r != nil && q.Match(r) {
+			c := *r
+			matches = append(matches, &c)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if q.Reverse {
+			return newerFirst(matches[j], matches[i])
+		}
+		return newerFirst(matches[i], matches[j])
+	})
+	if count > 0 && len(matches) > count {
+		matches = matches[:count]
+	}
+	return matches, nil
+}
+
+// Channels returns the channels that have records, in numeric order
+func (m *MemDB) Channels() ([]string, error) {
+	m.mu.RLock()
+	seen := make(map[string]bool)
+	for _, r := range m.records {
+		if r != nil && r.Channel != "" {
+			seen[r.Channel] = true
+		}
+	}
+	m.mu.RUnlock()
+
+	channels := make([]string, 0, len(seen))
+	for ch := range seen {
+		channels = append(channels, ch)
+	}
+	sortChannels(channels)
+	return channels, nil
+}
+
+// Prune removes the records in channel older than before and returns how
+// many were removed, or would be removed if dryRun is set. Pruned slots are
+// reused once the ring wraps around to them.
+func (m *MemDB) Prune(channel string, before time.Time, dryRun bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for i, r := range m.records {
+		if r != nil && r.Channel == channel && r.Time.Before(before) {
+			if !dryRun {
+				m.records[i] = nil
+			}
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,139 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// ids returns the IDs of records, in order
+func ids(records []*Record) []string {
+	out := []string{}
+	for _, r := range records {
+		out = append(out, r.ID)
+	}
+	return out
+}
+
+func TestMemDBWraparound(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		capacity, puts int
+		want           []string // stored IDs, newest first
+	}{
+		{capacity: 3, puts: 0, want: []string{}},
+		{capacity: 3, puts: 2, want: []string{"2", "1"}},
+		{capacity: 3, puts: 3, want: []string{"3", "2", "1"}},
+		{capacity: 3, puts: 4, want: []string{"4", "3", "2"}},
+		{capacity: 3, puts: 7, want: []string{"7", "6", "5"}},
+		{capacity: 0, puts: 2, want: []string{"2"}},
+	}
+	for _, tt := range tests {
+		m := NewMemDB(tt.capacity)
+		for i := 1; i <= tt.puts; i++ {
+			id, err := m.Put(&Record{Time: start.Add(time.Duration(i) * time.Second), Channel: "1", Text: strconv.Itoa(i)})
+			if err != nil || id != strconv.Itoa(i) {
+				t.Fatalf("Put %d = %q, %v", i, id, err)
+			}
+		}
+
+		got, err := m.Search(&Query{}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ids(got), tt.want) {
+			t.Errorf("capacity %d, %d puts: stored %v, want %v", tt.capacity, tt.puts, ids(got), tt.want)
+		}
+
+		for i := 1; i <= tt.puts+1; i++ {
+			id := strconv.Itoa(i)
+			r, err := m.Get(id)
+			stored := false
+			for _, w := range tt.want {
+				stored = stored || w == id
+			}
+			switch {
+			case stored && (err != nil || r.Text != id):
+				t.Errorf("capacity %d, %d puts: Get(%s) = %v, %v", tt.capacity, tt.puts, id, r, err)
+			case !stored && err != ErrNotFound:
+				t.Errorf("capacity %d, %d puts: Get(%s) = %v, %v, want ErrNotFound", tt.capacity, tt.puts, id, r, err)
+			}
+		}
+	}
+}
+
+func TestMemDBPrune(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	m := NewMemDB(4)
+	put := func(i int, channel string) {
+		if _, err := m.Put(&Record{Time: start.Add(time.Duration(i) * time.Hour), Channel: channel}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored := func() []string {
+		records, err := m.Search(&Query{Reverse: true}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids(records)
+	}
+	put(1, "1")
+	put(2, "2")
+	put(3, "1")
+	put(4, "2")
+
+	if n, _ := m.Prune("1", start.Add(3*time.Hour), true); n != 1 {
+		t.Errorf("dry run pruned %d, want 1", n)
+	}
+	if got := stored(); !reflect.DeepEqual(got, []string{"1", "2", "3", "4"}) {
+		t.Errorf("after a dry run stored %v", got)
+	}
+	if n, _ := m.Prune("1", start.Add(5*time.Hour), false); n != 2 {
+		t.Errorf("pruned %d, want 2", n)
+	}
+	if got := stored(); !reflect.DeepEqual(got, []string{"2", "4"}) {
+		t.Errorf("after pruning stored %v, want [2 4]", got)
+	}
+	if _, err := m.Get("1"); err != ErrNotFound {
+		t.Errorf("Get of a pruned record = %v, want ErrNotFound", err)
+	}
+	if channels, _ := m.Channels(); !reflect.DeepEqual(channels, []string{"2"}) {
+		t.Errorf("Channels = %v, want [2]", channels)
+	}
+
+	// new records take the place of the oldest slots, pruned or not
+	put(5, "1")
+	put(6, "1")
+	if got := stored(); !reflect.DeepEqual(got, []string{"4", "5", "6"}) {
+		t.Errorf("after wrapping around stored %v, want [4 5 6]", got)
+	}
+	if r, err := m.Get("5"); err != nil || r.Channel != "1" {
+		t.Errorf("Get(5) = %v, %v", r, err)
+	}
+}
+
+func TestOpenMemoryStore(t *testing.T) {
+	var last int64
+	for run := 0; run < 2; run++ {
+		store, err := openStore("memory", "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := store.Put(&Record{Time: time.Now(), Channel: "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, _ := strconv.ParseInt(id, 10, 64)
+		if n <= last {
+			t.Errorf("run %d assigned ID %d, not after %d of the previous run", run, n, last)
+		}
+		last = n
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metric is written to /metrics in the Prometheus text format
+type metric interface {
+	write(w io.Writer)
+}
+
+// registered metrics, in the order they are written
+var allMetrics []metric
+
+var (
+	channelTells = newCounterVec("chanbot_channel_tells_total",
+		"Channel tells received, by channel.", "channel")
+	privateTellsReceived = newCounter("chanbot_private_tells_received_total",
+		"Private tells received.")
+	privateTellsAnswered = newCounter("chanbot_private_tells_answered_total",
+		"Private tells answered.")
+	icsReconnects = newCounter("chanbot_ics_reconnects_total",
+		"Reconnections to the chess server.")
+	icsReceiveErrors = newCounter("chanbot_ics_receive_errors_total",
+		"Errors receiving output from the chess server.")
+	clientsConnected = newGaugeVec("chanbot_clients",
+		"Clients connected to the live feed, by transport.", "transport")
+	sendQueueDrops = newCounter("chanbot_send_queue_drops_total",
+		"Clients dropped for falling behind their send queue.")
+	logWriteSeconds = newHistogramVec("chanbot_log_write_seconds",
+		"Time taken to write a message to the chat log and the store.", "log",
+		[]float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1})
+)
+
+type counter struct {
+	name, help string
+	value      uint64
+}
+
+func newCounter(name, help string) *counter {
+	c := &counter{name: name, help: help}
+	allMetrics = append(allMetrics, c)
+	return c
+}
+
+func (c *counter) inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+func (c *counter) write(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	fmt.Fprintf(w, "%s %d\n", c.name, atomic.LoadUint64(&c.value))
+}
+
+// counterVec is a set of counters partitioned by the value of a label
+type counterVec struct {
+	name, help, label string
+
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+func newCounterVec(name, help, label string) *counterVec {
+	c := &counterVec{name: name, help: help, label: label, values: make(map[string]uint64)}
+	allMetrics = append(allMetrics, c)
+	return c
+}
+
+func (c *counterVec) inc(value string) {
+	c.mu.Lock()
+	c.values[value]++
+	c.mu.Unlock()
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	for _, v := range sortedLabels(c.values) {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", c.name, c.label, v, c.values[v])
+	}
+}
+
+// gaugeVec is a set of gauges partitioned by the value of a label
+type gaugeVec struct {
+	name, help, label string
+
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+func newGaugeVec(name, help, label string) *gaugeVec {
+	g := &gaugeVec{name: name, help: help, label: label, values: make(map[string]int64)}
+	allMetrics = append(allMetrics, g)
+	return g
+}
+
+func (g *gaugeVec) add(value string, delta int64) {
+	g.mu.Lock()
+	g.values[value] += delta
+	g.mu.Unlock()
+}
+
+func (g *gaugeVec) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	writeHeader(w, g.name, g.help, "gauge")
+	for _, v := range sortedLabels(g.values) {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", g.name, g.label, v, g.values[v])
+	}
+}
+
+// histogramVec is a set of histograms partitioned by the value of a label
+type histogramVec struct {
+	name, help, label string
+	buckets           []float64 // upper bounds, ascending
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func newHistogramVec(name, help, label string, buckets []float64) *histogramVec {
+	h := &histogramVec{name: name, help: help, label: label, buckets: buckets, values: make(map[string]*histogram)}
+	allMetrics = append(allMetrics, h)
+	return h
+}
+
+func (h *histogramVec) observe(value string, v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist := h.values[value]
+	if hist == nil {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[value] = hist
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		hist.counts[i]++
+	}
+	hist.count++
+	hist.sum += v
+}
+
+// since observes the time elapsed since start
+func (h *histogramVec) since(value string, start time.Time) {
+	h.observe(value, time.Since(start).Seconds())
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	for _, v := range sortedLabels(h.values) {
+		hist := h.values[v]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += hist.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s=%q,le=%q} %d\n", h.name, h.label, v, formatFloat(le), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s=%q,le=\"+Inf\"} %d\n", h.name, h.label, v, hist.count)
+		fmt.Fprintf(w, "%s_sum{%s=%q} %s\n", h.name, h.label, v, formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count{%s=%q} %d\n", h.name, h.label, v, hist.count)
+	}
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedLabels[V any](m map[string]V) []string {
+	labels := make([]string, 0, len(m))
+	for k := range m {
+		labels = append(labels, k)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// metricsHandler serves the registered metrics in the Prometheus text format
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, m := range allMetrics {
+		m.write(w)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"net/http"
+	"runtime/debug"
+	"strconv"
+)
+
+// recoverHandler turns a panic in next into a logged 500 response, so a bad
+// request cannot take down the server
+func recoverHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				// deliberately aborted, let net/http drop the connection
+				panic(err)
+			}
+			errLog.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+			// if the response has started this is ignored, and the client sees
+			// it end early
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hstsHandler tells browsers to only use HTTPS for the site from now on
+func hstsHandler(next http.Handler) http.Handler {
+	value := "max-age=" + strconv.Itoa(int(hstsMaxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+		next.ServeHTTP(w, r)
+	})
+}
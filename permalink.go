@@ -0,0 +1,85 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Messages shown before and after a permalinked message by default.
+	defaultContext = 10
+
+	// Maximum number of messages shown before and after a permalinked message.
+	maxContext = 100
+)
+
+// permalink returns the URL of a record's permalink page
+func permalink(r *Record) string {
+	return siteURL + "/m/" + r.ID
+}
+
+// permalinkHandler serves /m/{id}, showing a message along with the messages
+// around it in the same channel. The context parameter sets how many.
+func permalinkHandler(store DB, tmpl *template.Template) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, "/m/")
+		rec, err := store.Get(id)
+		if err == ErrNotFound {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			errLog.Printf("failed to get message %s: %v", id, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		n := defaultContext
+		if s := r.FormValue("context"); s != "" {
+			if n, err = strconv.Atoi(s); err != nil || n < 0 {
+				http.Error(w, "invalid context", http.StatusBadRequest)
+				return
+			}
+			if n > maxContext {
+				n = maxContext
+			}
+		}
+
+		var before, after []*Record
+		if n > 0 {
+			before, err = store.Search(&Query{Channels: []string{rec.Channel}, Cursor: cursorOf(rec)}, n)
+			if err == nil {
+				after, err = store.Search(&Query{Channels: []string{rec.Channel}, Cursor: cursorOf(rec), Reverse: true}, n)
+			}
+			if err != nil {
+				errLog.Printf("failed to get context of message %s: %v", id, err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		// the messages before come newest first
+		reverse(before)
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = tmpl.ExecuteTemplate(w, "message.html", struct {
+			Message *Record
+			Before  []*Record
+			After   []*Record
+		}{rec, before, after})
+		if err != nil {
+			errLog.Printf("failed to render message %s: %v", id, err)
+		}
+	}
+}
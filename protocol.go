@@ -0,0 +1,100 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+// protocolVersion is sent with every event, so clients can tell when the
+// protocol changes incompatibly
+const protocolVersion = 1
+
+// types of events sent to clients
+const (
+	eventMessage      = "message"
+	eventStatus       = "status"
+	eventError        = "error"
+	eventHistoryBatch = "history-batch"
+	eventViewerCount  = "viewer-count"
+	eventSubscription = "subscription"
+)
+
+// types of commands received from clients
+const (
+	commandSubscribe   = "subscribe"
+	commandUnsubscribe = "unsubscribe"
+	commandHistory     = "history"
+)
+
+// states of the ICS session reported by status events
+const (
+	statusConnecting   = "connecting"
+	statusConnected    = "connected"
+	statusDisconnected = "disconnected"
+)
+
+// event is a JSON frame sent to clients over /ws
+type event struct {
+	V        int       `json:"v"`
+	Type     string    `json:"type"`
+	Message  *Record   `json:"message,omitempty"`  // message, with no ID if it could not be stored
+	Messages []*Record `json:"messages,omitempty"` // history-batch, oldest first
+	Seq      int64     `json:"seq,omitempty"`      // message and history-batch, the last message's sequence number
+	Status   string    `json:"status,omitempty"`   // status
+	Error    string    `json:"error,omitempty"`    // error
+	Viewers  int       `json:"viewers,omitempty"`  // viewer-count
+
+	// Truncated is set on a history-batch when older messages the client
+	// missed were left out, or when the search for messages passing its
+	// filter stopped before reaching the oldest
+	Truncated bool `json:"truncated,omitempty"`
+	// More is set on a history-batch when there are older messages to page through
+	More bool `json:"more,omitempty"`
+	// Before echoes the sequence number a history command paged back from
+	Before int64 `json:"before,omitempty"`
+
+	Subscription *subscription `json:"subscription,omitempty"` // subscription
+}
+
+// command is a JSON frame received from clients over /ws.
+//
+// subscribe adds channels, users and text terms to the client's filter, and
+// unsubscribe removes them, or clears the whole filter when none are given.
+// Both are answered with a subscription event holding the resulting filter.
+//
+// history asks for up to count messages passing the filter that precede the
+// message with sequence number before, and is answered with a history-batch.
+type command struct {
+	Type string `json:"type"`
+	subscription
+	Before int64 `json:"before,omitempty"`
+	Count  int   `json:"count,omitempty"`
+}
+
+func messageEvent(r *Record) *event {
+	return &event{V: protocolVersion, Type: eventMessage, Message: r, Seq: r.Seq()}
+}
+
+func historyEvent(records []*Record) *event {
+	e := &event{V: protocolVersion, Type: eventHistoryBatch, Messages: records}
+	if len(records) > 0 {
+		e.Seq = records[len(records)-1].Seq()
+	}
+	return e
+}
+
+func statusEvent(status string) *event {
+	return &event{V: protocolVersion, Type: eventStatus, Status: status}
+}
+
+func errorEvent(err string) *event {
+	return &event{V: protocolVersion, Type: eventError, Error: err}
+}
+
+func viewerCountEvent(n int) *event {
+	return &event{V: protocolVersion, Type: eventViewerCount, Viewers: n}
+}
+
+func subscriptionEvent(s *subscription) *event {
+	return &event{V: protocolVersion, Type: eventSubscription, Subscription: s}
+}
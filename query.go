@@ -0,0 +1,259 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query describes a message search. Zero-valued fields match everything.
+//
+// The compact string form accepted by ParseQuery is a list of space separated
+// terms, where double quotes group words into a single term:
+//
+//	user:<handle>   (or u:, from:) messages by a user, case-insensitive
+//	ch:<channel>    (or channel:, c:) messages in a channel
+//	after:<time>    messages at or after a date or time
+//	before:<time>   messages before a date or time
+//	re:<regexp>     (or regex:) messages matching a regular expression
+//	cursor:<token>  messages past a cursor returned by a previous search
+//	<text>          messages containing the text, case-insensitive
+//
+// Repeated user: or ch: terms match messages by any of the users, or in any
+// of the channels.
+//
+// Times are either dates (2006-01-02), local times (2006-01-02T15:04) or
+// RFC 3339 timestamps, e.g.
+//
+//	user:foo ch:36 after:2026-10-01 "opening prep"
+type Query struct {
+	Users    []string       // if set, the user must be one of these, case-insensitive
+	Channels []string       // if set, the channel must be one of these, exactly
+	Text     []string       // all of these must appear in the message
+	Regex    *regexp.Regexp // if set, the message must match
+	After    time.Time      // inclusive
+	Before   time.Time      // exclusive
+	Cursor   string         // only records past this cursor
+	Reverse  bool           // return records oldest first, and page forwards from Cursor
+}
+
+// time layouts accepted for after: and before:
+var queryTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// queryKeys are the keys of the terms ParseQuery understands
+var queryKeys = map[string]bool{
+	"user": true, "u": true, "from": true,
+	"ch": true, "channel": true, "c": true,
+	"after": true, "before": true,
+	"re": true, "regex": true,
+	"cursor": true,
+}
+
+// ParseQuery parses the compact query syntax described on Query
+func ParseQuery(s string) (*Query, error) {
+	terms, err := splitTerms(s)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{}
+	for _, t := range terms {
+		key, value, ok := strings.Cut(t.text, ":")
+		if t.quoted || !ok || value == "" {
+			if t.text != "" {
+				q.Text = append(q.Text, t.text)
+			}
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "user", "u", "from":
+			q.Users = append(q.Users, value)
+		case "ch", "channel", "c":
+			if _, err := strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid channel %q", value)
+			}
+			q.Channels = append(q.Channels, value)
+		case "after":
+			if q.After, err = parseQueryTime(value); err != nil {
+				return nil, err
+			}
+		case "before":
+			if q.Before, err = parseQueryTime(value); err != nil {
+				return nil, err
+			}
+		case "re", "regex":
+			if q.Regex, err = regexp.Compile(value); err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %v", value, err)
+			}
+		case "cursor":
+			if _, _, err := parseCursor(value); err != nil {
+				return nil, err
+			}
+			q.Cursor = value
+		default:
+			// not a known key, so search for the term as is (e.g. "12:30")
+			q.Text = append(q.Text, t.text)
+		}
+	}
+	return q, nil
+}
+
+type queryTerm struct {
+	text   string
+	quoted bool
+}
+
+// splitTerms splits s on spaces, keeping double-quoted sections together.
+// Terms that start with a quote are always plain text, while a quoted value
+// after a key (re:"a b") belongs to that key.
+func splitTerms(s string) ([]queryTerm, error) {
+	var terms []queryTerm
+	var b strings.Builder
+	inQuote, started, quoted := false, false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			if !started {
+				quoted = true
+			}
+			started = true
+			inQuote = !inQuote
+		case unicode.IsSpace(r) && !inQuote:
+			if started {
+				terms = append(terms, queryTerm{text: b.String(), quoted: quoted})
+			}
+			b.Reset()
+			started, quoted = false, false
+		default:
+			started = true
+			b.WriteRune(r)
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if started {
+		terms = append(terms, queryTerm{text: b.String(), quoted: quoted})
+	}
+	return terms, nil
+}
+
+func parseQueryTime(s string) (time.Time, error) {
+	for _, layout := range queryTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// Match reports whether r satisfies the query
+func (q *Query) Match(r *Record) bool {
+	if len(q.Users) > 0 && !containsFold(q.Users, r.User) {
+		return false
+	}
+	if len(q.Channels) > 0 && !contains(q.Channels, r.Channel) {
+		return false
+	}
+	if !q.After.IsZero() && r.Time.Before(q.After) {
+		return false
+	}
+	if !q.Before.IsZero() && !r.Time.Before(q.Before) {
+		return false
+	}
+	if q.Cursor != "" {
+		t, id, err := parseCursor(q.Cursor)
+		if err != nil || q.Reverse == olderThan(r, t, id) || isCursorOf(r, t, id) {
+			return false
+		}
+	}
+	if len(q.Text) > 0 {
+		msg := strings.ToLower(r.Text)
+		for _, text := range q.Text {
+			if !strings.Contains(msg, strings.ToLower(text)) {
+				return false
+			}
+		}
+	}
+	if q.Regex != nil && !q.Regex.MatchString(r.Text) {
+		return false
+	}
+	return true
+}
+
+// containsFold reports whether list holds s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// cursorOf returns the cursor that pages past r
+func cursorOf(r *Record) string {
+	return strconv.FormatInt(r.Time.UnixNano(), 36) + "." + r.ID
+}
+
+func parseCursor(cursor string) (time.Time, int64, error) {
+	ts, id, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	nanos, err := strconv.ParseInt(ts, 36, 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return time.Unix(0, nanos), n, nil
+}
+
+// isCursorOf reports whether r is the record at time t with ID id
+func isCursorOf(r *Record, t time.Time, id int64) bool {
+	n, _ := strconv.ParseInt(r.ID, 10, 64)
+	return r.Time.Equal(t) && n == id
+}
+
+// newerFirst orders records newest first, for sorting search results
+func newerFirst(a, b *Record) bool {
+	id, _ := strconv.ParseInt(a.ID, 10, 64)
+	return olderThan(b, a.Time, id)
+}
+
+// olderThan reports whether r sorts before the record at time t with ID id.
+// Records are ordered by time, then by ID.
+func olderThan(r *Record, t time.Time, id int64) bool {
+	if !r.Time.Equal(t) {
+		return r.Time.Before(t)
+	}
+	n, _ := strconv.ParseInt(r.ID, 10, 64)
+	return n < id
+}
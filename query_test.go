@@ -0,0 +1,131 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	cursor := cursorOf(&Record{ID: "42", Time: day("2026-10-01")})
+
+	tests := []struct {
+		in      string
+		want    Query
+		re      string
+		wantErr bool
+	}{
+		{in: "", want: Query{}},
+		{in: "hello world", want: Query{Text: []string{"hello", "world"}}},
+		{in: `"opening prep"`, want: Query{Text: []string{"opening prep"}}},
+		{in: "user:foo ch:36", want: Query{Users: []string{"foo"}, Channels: []string{"36"}}},
+		{in: "u:foo c:1", want: Query{Users: []string{"foo"}, Channels: []string{"1"}}},
+		{in: "from:Foo channel:50 hi", want: Query{Users: []string{"Foo"}, Channels: []string{"50"}, Text: []string{"hi"}}},
+		{in: "user:foo user:bar ch:1 ch:2", want: Query{Users: []string{"foo", "bar"}, Channels: []string{"1", "2"}}},
+		{in: "after:2026-10-01 before:2026-10-02", want: Query{After: day("2026-10-01"), Before: day("2026-10-02")}},
+		{in: "after:2026-10-01T12:30", want: Query{After: day("2026-10-01").Add(12*time.Hour + 30*time.Minute)}},
+		{in: `re:"a b+"`, re: "a b+"},
+		{in: "regex:^gm", re: "^gm"},
+		{in: "cursor:" + cursor, want: Query{Cursor: cursor}},
+		// unknown keys, empty values and quoted terms are text
+		{in: "12:30", want: Query{Text: []string{"12:30"}}},
+		{in: "user:", want: Query{Text: []string{"user:"}}},
+		{in: `"user:foo"`, want: Query{Text: []string{"user:foo"}}},
+		{in: `""`, want: Query{}},
+
+		{in: "ch:abc", wantErr: true},
+		{in: "after:yesterday", wantErr: true},
+		{in: "before:2026-13-01", wantErr: true},
+		{in: "re:(", wantErr: true},
+		{in: "cursor:nope", wantErr: true},
+		{in: `"unterminated`, wantErr: true},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseQuery(%q) succeeded, want an error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tt.in, err)
+			continue
+		}
+		re := ""
+		if q.Regex != nil {
+			re = q.Regex.String()
+		}
+		if re != tt.re {
+			t.Errorf("ParseQuery(%q) regex = %q, want %q", tt.in, re, tt.re)
+		}
+		q.Regex = nil
+		if !reflect.DeepEqual(*q, tt.want) {
+			t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.in, *q, tt.want)
+		}
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	r := &Record{ID: "2", Time: time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local), Channel: "36", User: "Foo", Text: "Opening prep"}
+	older := cursorOf(&Record{ID: "1", Time: r.Time.Add(-time.Minute)})
+
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"", true},
+		{"user:foo", true},
+		{"user:bar", false},
+		{"ch:36 prep", true},
+		{"ch:37", false},
+		{"OPENING", true},
+		{"opening endgame", false},
+		{"re:^Open", true},
+		{"re:^open", false},
+		{"after:2026-10-01 before:2026-10-02", true},
+		{"after:2026-10-01T12:00", true},
+		{"before:2026-10-01T12:00", false},
+		{"cursor:" + older, false},
+		{"cursor:" + cursorOf(r), false},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.in)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", tt.in, err)
+		}
+		if got := q.Match(r); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	q := &Query{Cursor: older, Reverse: true}
+	if !q.Match(r) {
+		t.Errorf("reverse query from an older cursor does not match")
+	}
+	q = &Query{Users: []string{"bar", "FOO"}, Channels: []string{"1", "36"}}
+	if !q.Match(r) {
+		t.Errorf("%+v does not match", q)
+	}
+	q.Channels = []string{"1"}
+	if q.Match(r) {
+		t.Errorf("%+v matches", q)
+	}
+	// channels match exactly, like in the SQLite store
+	kibitz := &Record{Time: r.Time, Channel: "Game 5", User: "foo"}
+	if q := (&Query{Channels: []string{"game 5"}}); q.Match(kibitz) {
+		t.Errorf("%+v matches channel %q", q, kibitz.Channel)
+	}
+}
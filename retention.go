@@ -0,0 +1,160 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retention maps channels to how long their messages are kept. The "*" entry
+// applies to channels without a policy of their own, and channels without
+// any policy are kept forever.
+type Retention map[string]time.Duration
+
+// PruneResult reports what pruning removed from a channel
+type PruneResult struct {
+	Channel string
+	MaxAge  time.Duration
+	Cutoff  time.Time
+	Count   int
+}
+
+// ParseRetention parses a comma separated list of channel=age policies, e.g.
+// "36=365d,39=1w,*=30d". Ages are a number of days (d) or weeks (w), or a Go
+// duration such as 12h.
+func ParseRetention(s string) (Retention, error) {
+	policy := make(Retention)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		ch, age, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid retention policy %q", entry)
+		}
+		if _, err := strconv.Atoi(ch); err != nil && ch != "*" {
+			return nil, fmt.Errorf("invalid channel %q in retention policy", ch)
+		}
+		d, err := parseAge(age)
+		if err != nil {
+			return nil, err
+		}
+		policy[ch] = d
+	}
+	return policy, nil
+}
+
+func parseAge(s string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit != 0 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid retention age %q", s)
+		}
+		return time.Duration(n) * unit, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid retention age %q", s)
+	}
+	return d, nil
+}
+
+// MaxAge returns how long messages in channel are kept, or false if they are
+// kept forever
+func (p Retention) MaxAge(channel string) (time.Duration, bool) {
+	if d, ok := p[channel]; ok {
+		return d, true
+	}
+	d, ok := p["*"]
+	return d, ok
+}
+
+// Prune removes the messages that have outlived their channel's policy at
+// time now. With dryRun set, nothing is removed and the results report what
+// would be.
+func (p Retention) Prune(store DB, now time.Time, dryRun bool) ([]PruneResult, error) {
+	channels, err := store.Channels()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []PruneResult
+	for _, ch := range channels {
+		maxAge, ok := p.MaxAge(ch)
+		if !ok {
+			continue
+		}
+		cutoff := now.Add(-maxAge)
+		n, err := store.Prune(ch, cutoff, dryRun)
+		if err != nil {
+			return results, fmt.Errorf("failed to prune channel %s: %v", ch, err)
+		}
+		results = append(results, PruneResult{Channel: ch, MaxAge: maxAge, Cutoff: cutoff, Count: n})
+	}
+	return results, nil
+}
+
+// formatAge formats whole days as such and anything else as a Go duration
+func formatAge(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return strconv.Itoa(int(d/(24*time.Hour))) + "d"
+	}
+	return d.String()
+}
+
+// runPruner prunes store with the policy every interval, until the process exits
+func runPruner(store DB, policy Retention, interval time.Duration) {
+	for {
+		results, err := policy.Prune(store, time.Now(), false)
+		if err != nil {
+			errLog.Printf("failed to prune messages: %v", err)
+		}
+		for _, r := range results {
+			if r.Count > 0 {
+				errLog.Printf("pruned %d messages older than %s from channel %s", r.Count, r.Cutoff.Format(time.RFC3339), r.Channel)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+func pruneCommand(store DB, args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report what would be removed without removing it")
+	fs.Parse(args)
+
+	policy, err := ParseRetention(*retention)
+	if err != nil {
+		return err
+	}
+	if len(policy) == 0 {
+		return fmt.Errorf("no retention policy set, use -retention")
+	}
+
+	results, err := policy.Prune(store, time.Now(), *dryRun)
+	verb := "removed"
+	if *dryRun {
+		verb = "would remove"
+	}
+	for _, r := range results {
+		fmt.Printf("channel %s: %s %d messages older than %s (kept for %s)\n",
+			r.Channel, verb, r.Count, r.Cutoff.Format(time.RFC3339), formatAge(r.MaxAge))
+	}
+	return err
+}
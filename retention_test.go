@@ -0,0 +1,91 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRetention(t *testing.T) {
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		in      string
+		want    Retention
+		wantErr bool
+	}{
+		{in: "", want: Retention{}},
+		{in: "36=365d", want: Retention{"36": 365 * day}},
+		{in: "36=365d,39=1w,*=30d", want: Retention{"36": 365 * day, "39": 7 * day, "*": 30 * day}},
+		{in: " 1=12h , ,2=90m ", want: Retention{"1": 12 * time.Hour, "2": 90 * time.Minute}},
+
+		{in: "36", wantErr: true},
+		{in: "abc=1d", wantErr: true},
+		{in: "36=", wantErr: true},
+		{in: "36=0d", wantErr: true},
+		{in: "36=-1w", wantErr: true},
+		{in: "36=xd", wantErr: true},
+		{in: "36=-1h", wantErr: true},
+		{in: "36=forever", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRetention(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseRetention(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRetention(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRetention(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRetentionPrune(t *testing.T) {
+	now := time.Now()
+	store := NewMemDB(10)
+	for _, r := range []*Record{
+		{Time: now.Add(-48 * time.Hour), Channel: "1"},
+		{Time: now.Add(-48 * time.Hour), Channel: "2"},
+		{Time: now.Add(-48 * time.Hour), Channel: "3"},
+		{Time: now, Channel: "1"},
+	} {
+		store.Put(r)
+	}
+	policy, err := ParseRetention("1=1d,*=3d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := func(dryRun bool) map[string]int {
+		results, err := policy.Prune(store, now, dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]int)
+		for _, r := range results {
+			got[r.Channel] = r.Count
+		}
+		return got
+	}
+	want := map[string]int{"1": 1, "2": 0, "3": 0}
+	if got := counts(true); !reflect.DeepEqual(got, want) {
+		t.Errorf("dry run pruned %v, want %v", got, want)
+	}
+	if got := counts(false); !reflect.DeepEqual(got, want) {
+		t.Errorf("pruned %v, want %v", got, want)
+	}
+	if got := counts(false); !reflect.DeepEqual(got, map[string]int{"1": 0, "2": 0, "3": 0}) {
+		t.Errorf("pruned %v again", got)
+	}
+}
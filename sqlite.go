@@ -0,0 +1,267 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// schema migrations, applied in order and tracked with PRAGMA user_version
+var migrations = []string{
+	`CREATE TABLE messages (
+		id      INTEGER PRIMARY KEY AUTOINCREMENT,
+		time    INTEGER NOT NULL,
+		channel TEXT NOT NULL,
+		user    TEXT NOT NULL COLLATE NOCASE,
+		message TEXT NOT NULL
+	);
+	CREATE INDEX messages_time ON messages (time, id);
+	CREATE INDEX messages_channel_time ON messages (channel, time, id);
+	CREATE INDEX messages_user_time ON messages (user, time, id);`,
+
+	`ALTER TABLE messages RENAME COLUMN message TO text;
+	ALTER TABLE messages ADD COLUMN server TEXT NOT NULL DEFAULT '';
+	ALTER TABLE messages ADD COLUMN kind TEXT NOT NULL DEFAULT 'channel-tell';`,
+}
+
+// columns selected for a Record, in the order scanRecord expects
+const recordColumns = `id, time, server, kind, channel, user, text`
+
+// SQLiteDB is a DB stored in a single SQLite database file
+type SQLiteDB struct {
+	db *sql.DB
+}
+
+// NewSQLiteDB opens the SQLite database at path, creating and migrating it as needed
+func NewSQLiteDB(path string) (*SQLiteDB, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteDB{db: db}, nil
+}
+
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %v", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %v", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update schema version: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the underlying database
+func (s *SQLiteDB) Close() error {
+	return s.db.Close()
+}
+
+// CheckWritable inserts a row and rolls it back, which fails if the
+// database is locked, read-only or otherwise unable to take writes
+func (s *SQLiteDB) CheckWritable() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO messages (time, channel, user, text) VALUES (0, '', '', '')`)
+	return err
+}
+
+// Put stores r, assigning and returning its ID
+func (s *SQLiteDB) Put(r *Record) (string, error) {
+	res, err := s.db.Exec(`INSERT INTO messages (time, server, kind, channel, user, text) VALUES (?, ?, ?, ?, ?, ?)`,
+		r.Time.UnixNano(), r.Server, string(r.Kind), r.Channel, r.User, r.Text)
+	if err != nil {
+		return "", err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return "", err
+	}
+	r.ID = strconv.FormatInt(id, 10)
+	return r.ID, nil
+}
+
+// Get returns the record with the given ID
+func (s *SQLiteDB) Get(id string) (*Record, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	r, err := scanRecord(s.db.QueryRow(`SELECT `+recordColumns+` FROM messages WHERE id = ?`, n))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Search returns up to count records matching q, newest first unless
+// q.Reverse is set. A count <= 0 means no limit.
+func (s *SQLiteDB) Search(q *Query, count int) ([]*Record, error) {
+	var where []string
+	var args []interface{}
+	if len(q.Channels) > 0 {
+		where = append(where, "channel IN ("+placeholders(len(q.Channels))+")")
+		for _, ch := range q.Channels {
+			args = append(args, ch)
+		}
+	}
+	if len(q.Users) > 0 {
+		// the column collation makes this case-insensitive
+		where = append(where, "user IN ("+placeholders(len(q.Users))+")")
+		for _, user := range q.Users {
+			args = append(args, user)
+		}
+	}
+	if !q.After.IsZero() {
+		where = append(where, "time >= ?")
+		args = append(args, q.After.UnixNano())
+	}
+	if !q.Before.IsZero() {
+		where = append(where, "time < ?")
+		args = append(args, q.Before.UnixNano())
+	}
+	if q.Cursor != "" {
+		t, id, err := parseCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if q.Reverse {
+			where = append(where, "(time > ? OR (time = ? AND id > ?))")
+		} else {
+			where = append(where, "(time < ? OR (time = ? AND id < ?))")
+		}
+		args = append(args, t.UnixNano(), t.UnixNano(), id)
+	}
+	for _, text := range q.Text {
+		where = append(where, "instr(lower(text), lower(?)) > 0")
+		args = append(args, text)
+	}
+
+	query := `SELECT ` + recordColumns + ` FROM messages`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if q.Reverse {
+		query += " ORDER BY time, id"
+	} else {
+		query += " ORDER BY time DESC, id DESC"
+	}
+	// regular expressions are matched here rather than in SQLite, so the
+	// limit is applied while reading rows
+	if count > 0 && q.Regex == nil {
+		query += " LIMIT ?"
+		args = append(args, count)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*Record
+	for (count <= 0 || len(results) < count) && rows.Next() {
+		r, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		if q.Regex != nil && !q.Regex.MatchString(r.Text) {
+			continue
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// placeholders returns n comma separated query parameters
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRecord(row scanner) (*Record, error) {
+	var r Record
+	var id, ts int64
+	if err := row.Scan(&id, &ts, &r.Server, &r.Kind, &r.Channel, &r.User, &r.Text); err != nil {
+		return nil, err
+	}
+	r.ID = strconv.FormatInt(id, 10)
+	r.Time = time.Unix(0, ts)
+	return &r, nil
+}
+
+// Channels returns the channels that have records, in numeric order
+func (s *SQLiteDB) Channels() ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT channel FROM messages WHERE channel != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []string
+	for rows.Next() {
+		var ch string
+		if err := rows.Scan(&ch); err != nil {
+			return nil, err
+		}
+		channels = append(channels, ch)
+	}
+	sortChannels(channels)
+	return channels, rows.Err()
+}
+
+// Prune removes the records in channel older than before and returns how
+// many were removed, or would be removed if dryRun is set
+func (s *SQLiteDB) Prune(channel string, before time.Time, dryRun bool) (int, error) {
+	if dryRun {
+		var n int
+		err := s.db.QueryRow(`SELECT COUNT(*) FROM messages WHERE channel = ? AND time < ?`,
+			channel, before.UnixNano()).Scan(&n)
+		return n, err
+	}
+
+	res, err := s.db.Exec(`DELETE FROM messages WHERE channel = ? AND time < ?`, channel, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,202 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// openTestSQLite opens a new SQLite database in a temporary directory
+func openTestSQLite(t *testing.T) *SQLiteDB {
+	t.Helper()
+	s, err := NewSQLiteDB(filepath.Join(t.TempDir(), "chanbot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteDB(t *testing.T) {
+	s := openTestSQLite(t)
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	records := []*Record{
+		{Time: start, Channel: "1", User: "Foo", Text: "hello"},
+		{Time: start.Add(time.Minute), Channel: "2", User: "bar", Text: "Opening prep"},
+		// same time as the previous one, ordered by ID
+		{Time: start.Add(time.Minute), Channel: "1", User: "foo", Text: "gg"},
+		{Time: start.Add(2 * time.Minute), Channel: "Game 5", User: "baz", Text: "nice move", Kind: KindKibitz},
+		{Time: start.Add(3 * time.Minute), Channel: "36", User: "bar", Text: "opening book"},
+	}
+	for i, r := range records {
+		if r.Kind == "" {
+			r.Kind = KindChannelTell
+		}
+		r.Server = icsServer
+		id, err := s.Put(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := strconv.Itoa(i + 1); id != want || r.ID != want {
+			t.Fatalf("Put assigned ID %q (%q), want %q", id, r.ID, want)
+		}
+	}
+
+	got, err := s.Get("4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Time.Equal(records[3].Time) {
+		t.Errorf("Get(4) time %v, want %v", got.Time, records[3].Time)
+	}
+	got.Time = records[3].Time
+	if !reflect.DeepEqual(got, records[3]) {
+		t.Errorf("Get(4) = %+v, want %+v", got, records[3])
+	}
+	for _, id := range []string{"0", "6", "x"} {
+		if _, err := s.Get(id); err != ErrNotFound {
+			t.Errorf("Get(%q) = %v, want ErrNotFound", id, err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		q     *Query
+		count int
+		want  []string
+	}{
+		{"everything", &Query{}, 0, []string{"5", "4", "3", "2", "1"}},
+		{"count", &Query{}, 2, []string{"5", "4"}},
+		{"reverse", &Query{Reverse: true}, 2, []string{"1", "2"}},
+		{"user, any case", &Query{Users: []string{"FOO"}}, 0, []string{"3", "1"}},
+		{"channel", &Query{Channels: []string{"1"}}, 0, []string{"3", "1"}},
+		{"users, any case", &Query{Users: []string{"FOO", "Baz"}}, 0, []string{"4", "3", "1"}},
+		{"channels, exactly", &Query{Channels: []string{"2", "game 5"}}, 0, []string{"2"}},
+		{"text, any case", &Query{Text: []string{"OPENING"}}, 0, []string{"5", "2"}},
+		{"time", &Query{After: start.Add(time.Minute), Before: start.Add(3 * time.Minute)}, 0, []string{"4", "3", "2"}},
+		// the limit applies to the records the regexp matches
+		{"regexp", &Query{Regex: regexp.MustCompile("^[Oo]pening")}, 1, []string{"5"}},
+		{"regexp, reverse", &Query{Regex: regexp.MustCompile("^[Oo]pening"), Reverse: true}, 1, []string{"2"}},
+		{"older than cursor", &Query{Cursor: cursorOf(records[2])}, 0, []string{"2", "1"}},
+		{"newer than cursor", &Query{Cursor: cursorOf(records[1]), Reverse: true}, 0, []string{"3", "4", "5"}},
+		{"newer than cursor, count", &Query{Cursor: cursorOf(records[0]), Reverse: true}, 2, []string{"2", "3"}},
+	}
+	for _, tt := range tests {
+		results, err := s.Search(tt.q, tt.count)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(ids(results), tt.want) {
+			t.Errorf("%s: found %v, want %v", tt.name, ids(results), tt.want)
+		}
+	}
+
+	// the memory store finds the same records
+	m := NewMemDB(len(records))
+	for _, r := range records {
+		c := *r
+		m.Put(&c)
+	}
+	for _, tt := range tests {
+		results, err := m.Search(tt.q, tt.count)
+		if err != nil || !reflect.DeepEqual(ids(results), tt.want) {
+			t.Errorf("%s: MemDB found %v, %v, want %v", tt.name, ids(results), err, tt.want)
+		}
+	}
+
+	channels, err := s.Channels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sortChannels puts channels that are not numbers first
+	if want := []string{"Game 5", "1", "2", "36"}; !reflect.DeepEqual(channels, want) {
+		t.Errorf("Channels = %v, want %v", channels, want)
+	}
+
+	if err := s.CheckWritable(); err != nil {
+		t.Errorf("CheckWritable: %v", err)
+	}
+	if results, _ := s.Search(&Query{}, 0); len(results) != len(records) {
+		t.Errorf("CheckWritable left %d records, want %d", len(results), len(records))
+	}
+}
+
+func TestSQLiteDBPrune(t *testing.T) {
+	s := openTestSQLite(t)
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	for i, ch := range []string{"1", "2", "1", "1"} {
+		if _, err := s.Put(&Record{Time: start.Add(time.Duration(i) * time.Hour), Channel: ch, User: "foo", Text: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := s.Prune("1", start.Add(3*time.Hour), true); err != nil || n != 2 {
+		t.Errorf("dry run pruned %d, %v, want 2", n, err)
+	}
+	if results, _ := s.Search(&Query{}, 0); len(results) != 4 {
+		t.Errorf("dry run left %d records, want 4", len(results))
+	}
+	if n, err := s.Prune("1", start.Add(3*time.Hour), false); err != nil || n != 2 {
+		t.Errorf("pruned %d, %v, want 2", n, err)
+	}
+	results, err := s.Search(&Query{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"4", "2"}; !reflect.DeepEqual(ids(results), want) {
+		t.Errorf("after pruning found %v, want %v", ids(results), want)
+	}
+	if n, _ := s.Prune("1", start.Add(3*time.Hour), false); n != 0 {
+		t.Errorf("pruned %d again", n)
+	}
+}
+
+func TestSQLiteDBMigrateV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chanbot.db")
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{migrations[0], "PRAGMA user_version = 1"} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO messages (time, channel, user, message) VALUES (?, '36', 'foo', 'hello')`, at.UnixNano()); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := NewSQLiteDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil || version != len(migrations) {
+		t.Errorf("schema version %d, %v, want %d", version, err, len(migrations))
+	}
+
+	r, err := s.Get("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Kind != KindChannelTell || r.Channel != "36" || r.User != "foo" || r.Text != "hello" || !r.Time.Equal(at) {
+		t.Errorf("migrated record %+v", r)
+	}
+	if _, err := s.Put(&Record{Time: at, Channel: "36", User: "bar", Text: "hi"}); err != nil {
+		t.Errorf("Put after migrating: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// sseRetry is how long EventSource clients wait before reconnecting
+const sseRetry = 5 * time.Second
+
+// sseHandler streams the events of the WebSocket protocol as Server-Sent
+// Events, for clients that cannot use /ws. Events carrying messages have the
+// sequence number of the last one as their ID, so reconnecting clients resume
+// through Last-Event-ID. The channel, user and text parameters set the
+// filter, which cannot be changed afterwards. Connections count towards the
+// same limits as WebSockets.
+func sseHandler(h *hub, limits *connLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		ip := clientIP(r)
+		if !limits.acquire(ip) {
+			tooManyConnections(w)
+			return
+		}
+		defer limits.release(ip)
+
+		since := r.Header.Get("Last-Event-ID")
+		if since == "" {
+			since = r.FormValue("since")
+		}
+		c := h.subscribe(filterFromRequest(r), since, backfillFromRequest(r))
+		defer h.unsubscribe(c)
+		clientsConnected.add("sse", 1)
+		defer clientsConnected.add("sse", -1)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// keep reverse proxies from buffering the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+		flusher.Flush()
+
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case e, ok := <-c.send:
+				if !ok {
+					// dropped by the hub for falling behind
+					return
+				}
+				if err := writeSSE(w, e); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSE writes e as a single Server-Sent Event
+func writeSSE(w io.Writer, e *event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if e.Seq > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", e.Seq); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+	return err
+}
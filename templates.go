@@ -0,0 +1,30 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"html/template"
+	"io/fs"
+	"time"
+)
+
+// templateFuncs are available to all page templates
+var templateFuncs = template.FuncMap{
+	"datetime": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+	"isotime": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+	"day": func(t time.Time) string {
+		return t.Format(dayLayout)
+	},
+}
+
+// parseTemplates parses the page templates in the templates directory of fsys
+func parseTemplates(fsys fs.FS) (*template.Template, error) {
+	return template.New("").Funcs(templateFuncs).ParseFS(fsys, "templates/*.html")
+}
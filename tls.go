@@ -0,0 +1,113 @@
+// Copyright © 2026 Free Chess Club <help@freechess.club>
+//
+// See license in LICENSE file
+//
+
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// How often the certificate files are checked for changes.
+	certCheckInterval = 10 * time.Second
+
+	// How long browsers should keep to HTTPS after seeing the HSTS header.
+	hstsMaxAge = 365 * 24 * time.Hour
+)
+
+// certReloader serves a certificate from a pair of files, loading it again
+// when either file changes, e.g. after a renewal
+type certReloader struct {
+	certFile, keyFile string
+
+	mu              sync.Mutex
+	cert            *tls.Certificate
+	certMod, keyMod time.Time
+	lastCheck       time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load reads the certificate files. The caller must hold c.mu, or be the
+// only user of c.
+func (c *certReloader) load() error {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.cert = &cert
+	c.certMod, c.keyMod = certInfo.ModTime(), keyInfo.ModTime()
+	c.lastCheck = time.Now()
+	return nil
+}
+
+// changed reports whether either file has been modified since it was loaded
+func (c *certReloader) changed() bool {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return false
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return false
+	}
+	return !certInfo.ModTime().Equal(c.certMod) || !keyInfo.ModTime().Equal(c.keyMod)
+}
+
+// GetCertificate returns the current certificate, for tls.Config. A
+// certificate that fails to reload is logged, and the previous one kept.
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.lastCheck) >= certCheckInterval {
+		c.lastCheck = time.Now()
+		if c.changed() {
+			if err := c.load(); err != nil {
+				errLog.Printf("failed to reload TLS certificate: %v", err)
+			} else {
+				errLog.Printf("reloaded TLS certificate from %s", c.certFile)
+			}
+		}
+	}
+	return c.cert, nil
+}
+
+// redirectToHTTPS sends plain HTTP requests to the HTTPS server, except for
+// health checks, which are served as they are
+func redirectToHTTPS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if _, port, err := net.SplitHostPort(*tlsAddr); err == nil && port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}